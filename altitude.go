@@ -0,0 +1,75 @@
+package neighborhood
+
+import "math"
+
+// Altituder is an optional interface to define a Point altitude, for indexes in altitude mode (see KDTreeOptions)
+type Altituder interface {
+	// Alt gets the Point altitude in meters above sea level
+	Alt() float64
+}
+
+// altOf gets the Point altitude in meters if it implements the optional Altituder interface, or zero otherwise
+func altOf(pt Point) float64 {
+	if a, ok := pt.(Altituder); ok {
+		return a.Alt()
+	}
+	return 0
+}
+
+// SlantRangeKm gets the straight-line distance in kilometers between two Points, including their altitudes
+// (see Altituder), as if both were in Earth-centered 3D space
+func SlantRangeKm(pt1, pt2 Point) float64 {
+	h := haverSinDist(pt1, pt2.Lon(), pt2.Lat(), math.Cos(pt1.Lat()*rad))
+	return slantRange(earthRadiusKm+altOf(pt1)/1000, earthRadiusKm+altOf(pt2)/1000, h)
+}
+
+// slantRange gets the straight-line distance between two points at distances r1 and r2 from the center of the Earth,
+// with a central angle between them given by its haversine
+func slantRange(r1, r2, haverSinTheta float64) float64 {
+	// law of cosines, using cos(theta) = 1 - 2 hav(theta)
+	d2 := (r1-r2)*(r1-r2) + 4*r1*r2*haverSinTheta
+	return math.Sqrt(math.Max(d2, 0))
+}
+
+// slantSearch creates a search for the nearest points to the origin by slant range. The caller is responsible for
+// locking.
+func (idx *KDTree) slantSearch(origin Point, accept Accepter) *kdSearch {
+	cosLat := math.Cos(origin.Lat() * rad)
+	r1 := earthRadiusKm + altOf(origin)/1000
+	minR, maxR := earthRadiusKm+idx.minAlt/1000, earthRadiusKm+idx.maxAlt/1000
+
+	search := idx.newSearch(accept,
+		func(i int) float64 {
+			h := haverSinDist(origin, idx.coords[2*i], idx.coords[2*i+1], cosLat)
+			return slantRange(r1, earthRadiusKm+idx.alts[i]/1000, h)
+		},
+		func(node *kdTreeNode) float64 {
+			// the slant range grows with the central angle, so use the smallest angle to the node; then pick the
+			// altitude within the index's range that is closest to the foot of the perpendicular from the origin
+			h := boxDist(origin, cosLat, node.bounds)
+			r2 := math.Max(minR, math.Min(maxR, r1*(1-2*h)))
+			return slantRange(r1, r2, h)
+		},
+	)
+	search.tieEnd = idx.nearbyTieEnd()
+	return search
+}
+
+// buildAltitudes stores the altitude of every point and the range of altitudes in altitude mode
+func (idx *KDTree) buildAltitudes() {
+	if !idx.altitude {
+		return
+	}
+	idx.alts = idx.alts[:0]
+	idx.minAlt, idx.maxAlt = 0, 0
+	for i, id := range idx.ids {
+		alt := altOf(idx.points[id])
+		idx.alts = append(idx.alts, alt)
+		if i == 0 || alt < idx.minAlt {
+			idx.minAlt = alt
+		}
+		if i == 0 || alt > idx.maxAlt {
+			idx.maxAlt = alt
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package neighborhood
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+type AltitudePoint struct {
+	Point
+	Name   string
+	Meters float64
+}
+
+func (p *AltitudePoint) Alt() float64 {
+	return p.Meters
+}
+
+func TestSlantRangeKm(t *testing.T) {
+	ground := &AltitudePoint{Point: points["seattle"], Name: "ground"}
+	plane := &AltitudePoint{Point: points["seattle"], Name: "plane", Meters: 10_000}
+	assertEqual(t, 10, int(SlantRangeKm(ground, plane)+0.5))
+	assertEqual(t, 10, int(SlantRangeKm(plane, ground)+0.5))
+
+	// without altitude, the slant range is the chord, which is a little shorter than the great circle
+	chord := SlantRangeKm(points["seattle"], points["memphis"])
+	assertEqual(t, true, chord < distanceKm(points["seattle"], points["memphis"]))
+	assertEqual(t, true, chord > distanceKm(points["seattle"], points["memphis"])-50)
+}
+
+func TestKDTree_Nearby_Altitude(t *testing.T) {
+	pts := []Point{
+		&AltitudePoint{Point: points["seattle"], Name: "plane-over-seattle", Meters: 30_000},
+		&AltitudePoint{Point: points["woodinville"], Name: "woodinville"},
+		namedPoint("memphis"),
+	}
+	origin := points["seattle"]
+
+	// by default, altitude is ignored
+	results := NewIndex().Load(pts...).Nearby(origin, 2, AcceptAny)
+	assertEqual(t, "plane-over-seattle", results[0].(*AltitudePoint).Name)
+	assertEqual(t, "woodinville", results[1].(*AltitudePoint).Name)
+
+	opts := DefaultKDTreeOptions()
+	opts.Altitude = true
+	idx := NewKDTreeIndex(opts).Load(pts...)
+	results = idx.Nearby(origin, 3, AcceptAny)
+	assertEqual(t, 3, len(results))
+	assertEqual(t, "woodinville", results[0].(*AltitudePoint).Name)
+	assertEqual(t, "plane-over-seattle", results[1].(*AltitudePoint).Name)
+	assertEqual(t, "memphis", results[2].(*NamedPoint).Name)
+
+	// the origin's altitude counts too
+	up := &AltitudePoint{Point: points["seattle"], Meters: 30_000}
+	results = idx.Nearby(up, 1, AcceptAny)
+	assertEqual(t, "plane-over-seattle", results[0].(*AltitudePoint).Name)
+}
+
+func TestKDTree_Nearby_Altitude_Random(t *testing.T) {
+	rnd := rand.New(rand.NewSource(9))
+	pts := make([]Point, 5_000)
+	for i := range pts {
+		pts[i] = &AltitudePoint{
+			Point:  NewCoordinates(rnd.Float64()*360-180, rnd.Float64()*180-90),
+			Meters: rnd.Float64() * 2_000_000, // up to low Earth orbit
+		}
+	}
+	opts := KDTreeOptions{NodeSize: 8, Altitude: true}
+	idx := NewKDTreeIndex(opts).Load(pts...)
+
+	for trial := 0; trial < 10; trial++ {
+		origin := &AltitudePoint{
+			Point:  NewCoordinates(rnd.Float64()*360-180, rnd.Float64()*180-90),
+			Meters: rnd.Float64() * 100_000,
+		}
+		var expected []float64
+		for _, pt := range pts {
+			expected = append(expected, SlantRangeKm(origin, pt))
+		}
+		sort.Float64s(expected)
+
+		results := idx.Nearby(origin, 10, AcceptAny)
+		assertEqual(t, 10, len(results))
+		for i, result := range results {
+			assertEqual(t, int(expected[i]*1000), int(SlantRangeKm(origin, result)*1000))
+		}
+	}
+}
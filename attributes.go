@@ -0,0 +1,157 @@
+package neighborhood
+
+import "math"
+
+// Attribute declares a categorical Point attribute (like country or sponsor) by name
+type Attribute struct {
+	Name  string
+	Value func(p Point) string
+}
+
+// Match selects Points by categorical attributes. A Point matches if, for every attribute name in the Match,
+// the Point's value is one of the listed values.
+type Match map[string][]string
+
+// NearbyMatching finds the k nearest Points to the origin that match the attribute values and meet the Accepter
+// criteria. Every attribute in the Match must be declared in the KDTreeOptions Attributes. kd-tree nodes that do
+// not contain any matching Points are skipped entirely, so highly selective matches stay fast.
+// Results are ordered by great-circle distance, even in altitude mode, and then like Nearby. NearbyMatching may
+// return less than k results if it cannot find k Points in the Index that match.
+func (idx *KDTree) NearbyMatching(origin Point, k int, match Match, accept Accepter) []Point {
+	idx.RLock()
+	defer idx.RUnlock()
+
+	// convert the matching values of each attribute to a bitset of value codes
+	type filter struct {
+		ai      *attributeIndex
+		allowed bitset
+	}
+	var filters []filter
+	for name, values := range match {
+		ai := idx.attributeIndex(name)
+		if ai == nil {
+			return nil // undeclared attributes never match
+		}
+		allowed := newBitset(len(ai.codes))
+		for _, value := range values {
+			if code, ok := ai.codes[value]; ok {
+				allowed.set(code)
+			}
+		}
+		filters = append(filters, filter{ai: ai, allowed: allowed})
+	}
+
+	cosLat := math.Cos(origin.Lat() * rad)
+	search := idx.newSearch(accept,
+		func(i int) float64 {
+			for _, f := range filters {
+				if !f.allowed.has(f.ai.values[i]) {
+					return math.Inf(1) // not a matching point
+				}
+			}
+			return haverSinDist(origin, idx.coords[2*i], idx.coords[2*i+1], cosLat)
+		},
+		func(node *kdTreeNode) float64 {
+			if node.Right < node.Left {
+				return math.Inf(1) // empty node
+			}
+			for _, f := range filters {
+				if !f.allowed.intersects(f.ai.nodeValues[(node.Left+node.Right)>>1]) {
+					return math.Inf(1) // no matching points in the node
+				}
+			}
+			return boxDist(origin, cosLat, node.bounds)
+		},
+	)
+	search.tieEnd = idx.haverSinTieEnd()
+	return search.take(k)
+}
+
+// attributeIndex holds the values of a categorical attribute for every point and a summary for every node
+type attributeIndex struct {
+	name       string
+	codes      map[string]int // value codes by value
+	values     []int          // value code of each point, by kd-tree array index
+	nodeValues []bitset       // value codes of the points inside each node, by the node's middle index
+}
+
+// attributeIndex gets the attribute index with a given name, or nil if it was not declared
+func (idx *KDTree) attributeIndex(name string) *attributeIndex {
+	for _, ai := range idx.attrIndexes {
+		if ai.name == name {
+			return ai
+		}
+	}
+	return nil
+}
+
+// buildAttributes stores the values of the declared attributes for every point, and summarizes them for every node
+func (idx *KDTree) buildAttributes() {
+	idx.attrIndexes = idx.attrIndexes[:0]
+	for _, attr := range idx.attrs {
+		ai := &attributeIndex{
+			name:       attr.Name,
+			codes:      make(map[string]int),
+			values:     make([]int, len(idx.ids)),
+			nodeValues: make([]bitset, len(idx.ids)),
+		}
+		for i, id := range idx.ids {
+			value := attr.Value(idx.points[id])
+			code, ok := ai.codes[value]
+			if !ok {
+				code = len(ai.codes)
+				ai.codes[value] = code
+			}
+			ai.values[i] = code
+		}
+
+		var build func(node *kdTreeNode) bitset
+		build = func(node *kdTreeNode) bitset {
+			summary := newBitset(len(ai.codes))
+			if node.Right < node.Left {
+				return summary
+			}
+			if idx.isLeaf(node) {
+				for i := node.Left; i <= node.Right; i++ {
+					summary.set(ai.values[i])
+				}
+			} else {
+				m, leftNode, rightNode := idx.split(node)
+				summary.set(ai.values[m])
+				summary.union(build(leftNode))
+				summary.union(build(rightNode))
+			}
+			// each node has a distinct middle index, since a node's middle point is not part of either child node
+			ai.nodeValues[(node.Left+node.Right)>>1] = summary
+			return summary
+		}
+		build(idx.root())
+		idx.attrIndexes = append(idx.attrIndexes, ai)
+	}
+}
+
+// bitset is a set of small non-negative integers
+type bitset []uint64
+
+func newBitset(size int) bitset {
+	return make(bitset, (size+63)/64)
+}
+
+func (b bitset) set(i int) { b[i/64] |= 1 << (uint(i) % 64) }
+
+func (b bitset) has(i int) bool { return b[i/64]&(1<<(uint(i)%64)) != 0 }
+
+func (b bitset) union(other bitset) {
+	for i := range other {
+		b[i] |= other[i]
+	}
+}
+
+func (b bitset) intersects(other bitset) bool {
+	for i := range b {
+		if i < len(other) && b[i]&other[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package neighborhood
+
+import (
+	"fmt"
+	"testing"
+)
+
+type AttributedPoint struct {
+	Point
+	Country string
+	Sponsor string
+}
+
+func attributedPoints(n int) []Point {
+	pts := globalPoints(n)
+	for i, pt := range pts {
+		pts[i] = &AttributedPoint{
+			Point:   pt,
+			Country: fmt.Sprint("country-", i%50),
+			Sponsor: fmt.Sprint("sponsor-", i%7),
+		}
+	}
+	return pts
+}
+
+func attributedOptions() KDTreeOptions {
+	opts := DefaultKDTreeOptions()
+	opts.NodeSize = 8
+	opts.Attributes = []Attribute{
+		{Name: "country", Value: func(p Point) string { return p.(*AttributedPoint).Country }},
+		{Name: "sponsor", Value: func(p Point) string { return p.(*AttributedPoint).Sponsor }},
+	}
+	return opts
+}
+
+func TestKDTree_NearbyMatching(t *testing.T) {
+	idx := NewKDTreeIndex(attributedOptions()).Load(attributedPoints(10_000)...).(*KDTree)
+	origin := NewCoordinates(-122, 47)
+
+	match := Match{"country": {"country-3", "country-4"}, "sponsor": {"sponsor-5"}}
+	results := idx.NearbyMatching(origin, 10, match, AcceptAny)
+	expected := idx.Nearby(origin, 10, func(p Point) bool {
+		ap := p.(*AttributedPoint)
+		return (ap.Country == "country-3" || ap.Country == "country-4") && ap.Sponsor == "sponsor-5"
+	})
+	assertEqual(t, 10, len(results))
+	for i := range expected {
+		assertEqual(t, expected[i], results[i])
+	}
+
+	// the Accepter still applies
+	results = idx.NearbyMatching(origin, 10, match, func(p Point) bool { return p.Lat() < 0 })
+	assertEqual(t, 10, len(results))
+	for _, result := range results {
+		assertEqual(t, true, result.Lat() < 0)
+		assertEqual(t, "sponsor-5", result.(*AttributedPoint).Sponsor)
+	}
+
+	// an empty Match is a regular nearby search
+	results = idx.NearbyMatching(origin, 10, Match{}, AcceptAny)
+	expected = idx.Nearby(origin, 10, AcceptAny)
+	for i := range expected {
+		assertEqual(t, expected[i], results[i])
+	}
+}
+
+func TestKDTree_NearbyMatching_NoMatch(t *testing.T) {
+	idx := NewKDTreeIndex(attributedOptions()).Load(attributedPoints(1_000)...).(*KDTree)
+	origin := NewCoordinates(-122, 47)
+
+	assertEqual(t, 0, len(idx.NearbyMatching(origin, 10, Match{"country": {"atlantis"}}, AcceptAny)))
+	assertEqual(t, 0, len(idx.NearbyMatching(origin, 10, Match{"country": {}}, AcceptAny)))
+	assertEqual(t, 0, len(idx.NearbyMatching(origin, 10, Match{"color": {"blue"}}, AcceptAny)))
+
+	// only one point has this combination
+	idx.Add(&AttributedPoint{Point: NewCoordinates(10, 10), Country: "atlantis", Sponsor: "sponsor-1"})
+	results := idx.NearbyMatching(origin, 10, Match{"country": {"atlantis"}}, AcceptAny)
+	assertEqual(t, 1, len(results))
+	assertEqual(t, "atlantis", results[0].(*AttributedPoint).Country)
+
+	empty := NewKDTreeIndex(attributedOptions()).(*KDTree)
+	assertEqual(t, 0, len(empty.NearbyMatching(origin, 10, Match{"country": {"atlantis"}}, AcceptAny)))
+}
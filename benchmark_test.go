@@ -51,4 +51,116 @@ func benchmarkNearby(b *testing.B, n, k int) {
 	for i := 0; i < b.N; i++ {
 		result = idx.Nearby(origin, k, AcceptAny)
 	}
-}
\ No newline at end of file
+}
+
+var count int
+
+func BenchmarkWithin_100k_1000km(b *testing.B) {
+	points := globalPoints(100_000)
+	origin := namedPoint("seattle")
+	idx := NewIndex().Load(points...).(*KDTree)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		result = idx.Within(origin, 1000, AcceptAny)
+	}
+}
+
+func BenchmarkCountWithin_100k_1000km(b *testing.B) {
+	points := globalPoints(100_000)
+	origin := namedPoint("seattle")
+	idx := NewIndex().Load(points...).(*KDTree)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		count = idx.CountWithin(origin, 1000)
+	}
+}
+
+func BenchmarkNearby_100k_k10_SelectiveAccepter(b *testing.B) {
+	points := attributedPoints(100_000)
+	origin := namedPoint("seattle")
+	idx := NewKDTreeIndex(attributedOptions()).Load(points...)
+	accept := func(p Point) bool {
+		return p.(*AttributedPoint).Country == "country-3" && p.(*AttributedPoint).Sponsor == "sponsor-5"
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		result = idx.Nearby(origin, 10, accept)
+	}
+}
+
+func BenchmarkNearbyMatching_100k_k10_Selective(b *testing.B) {
+	points := attributedPoints(100_000)
+	origin := namedPoint("seattle")
+	idx := NewKDTreeIndex(attributedOptions()).Load(points...).(*KDTree)
+	match := Match{"country": {"country-3"}, "sponsor": {"sponsor-5"}}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		result = idx.NearbyMatching(origin, 10, match, AcceptAny)
+	}
+}
+
+func BenchmarkNearby_ECEF_100k_k10(b *testing.B) {
+	points := globalPoints(100_000)
+	origin := namedPoint("seattle")
+	idx := NewECEFTreeIndex(DefaultECEFTreeOptions()).Load(points...)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		result = idx.Nearby(origin, 10, AcceptAny)
+	}
+}
+
+func BenchmarkNearby_ECEF_100k_k10_Pole(b *testing.B) {
+	benchmarkNearbyAt(b, NewECEFTreeIndex(DefaultECEFTreeOptions()), NewCoordinates(0, 89.5))
+}
+
+func BenchmarkNearby_KDTree_100k_k10_Pole(b *testing.B) {
+	benchmarkNearbyAt(b, NewIndex(), NewCoordinates(0, 89.5))
+}
+
+func benchmarkNearbyAt(b *testing.B, idx Index, origin Point) {
+	idx.Load(globalPoints(100_000)...)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		result = idx.Nearby(origin, 10, AcceptAny)
+	}
+}
+
+func BenchmarkNearby_GeohashGrid_100k_k10(b *testing.B) {
+	benchmarkNearbyAt(b, NewGeohashGridIndex(DefaultGeohashGridOptions()), namedPoint("seattle"))
+}
+
+func BenchmarkAddRemove_GeohashGrid_100k(b *testing.B) {
+	idx := NewGeohashGridIndex(DefaultGeohashGridOptions()).Load(globalPoints(100_000)...).(*GeohashGrid)
+	pt := namedPoint("seattle")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		idx.Add(pt)
+		idx.Remove(pt)
+	}
+}
+
+func BenchmarkNearby_CellIndex_100k_k10(b *testing.B) {
+	benchmarkNearbyAt(b, NewCellIndex(DefaultCellIndexOptions()), namedPoint("seattle"))
+}
+
+func BenchmarkNearby_RTree_100k_k10(b *testing.B) {
+	benchmarkNearbyAt(b, NewRTreeIndex(DefaultRTreeOptions()), namedPoint("seattle"))
+}
+
+func BenchmarkNearby_BruteForce_100k_k10(b *testing.B) {
+	benchmarkNearbyAt(b, NewBruteForceIndex(), namedPoint("seattle"))
+}
+
+func BenchmarkNearby_Sharded_100k_k10(b *testing.B) {
+	benchmarkNearbyAt(b, NewShardedIndex(DefaultShardedOptions()), namedPoint("seattle"))
+}
+
+func BenchmarkAdd_Sharded_100k(b *testing.B) {
+	si := NewShardedIndex(DefaultShardedOptions())
+	si.Load(globalPoints(100_000)...)
+	pt := namedPoint("seattle")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		si.Add(pt)
+	}
+}
@@ -0,0 +1,72 @@
+package neighborhood
+
+import (
+	"container/heap"
+	"math"
+	"sync"
+)
+
+// BruteForce implements the Index interface by comparing the origin with every Point on each search. Its results
+// are the same as the KDTree's with default options, including the order of tied Points (see Ranker), so it can
+// verify other Index implementations. It is also a simple choice for tiny datasets, since Load does no work.
+type BruteForce struct {
+	sync.RWMutex
+	points []Point
+}
+
+// NewBruteForceIndex creates a new BruteForce Index implementation
+func NewBruteForceIndex() Index {
+	return &BruteForce{}
+}
+
+// Load adds searchable Points to the Index.
+// Each call to Load will replace all Points in the Index with the provided Points.
+// Load mutates and returns the Index to allow call chaining.
+func (idx *BruteForce) Load(points ...Point) Index {
+	idx.Lock()
+	defer idx.Unlock()
+
+	idx.points = points
+	return idx
+}
+
+// Add will update the index with the provided points, while persisting the existing points.
+// Add returns the Index after it is complete to allow call chaining.
+func (idx *BruteForce) Add(points ...Point) Index {
+	idx.Lock()
+	defer idx.Unlock()
+
+	idx.points = append(idx.points, points...)
+	return idx
+}
+
+// Nearby finds the k nearest Points to the origin that meet the Accepter criteria.
+// If there are multiple Points that are the same distance from the origin and the Points implement the Ranker
+// interface, the higher ranking Points will be preferred. Points with the same distance and rank are returned in the
+// order they were loaded (by Load, followed by Add). Nearby may return less than k results if it cannot find k
+// Points in the Index that meet the Accepter criteria.
+func (idx *BruteForce) Nearby(origin Point, k int, accept Accepter) []Point {
+	idx.RLock()
+	defer idx.RUnlock()
+
+	cosLat := math.Cos(origin.Lat() * rad)
+	q := make(priorityQueue, 0, len(idx.points))
+	for i, pt := range idx.points {
+		if accept(pt) {
+			q = append(q, &item{
+				point:    pt,
+				distance: haverSinDist(origin, pt.Lon(), pt.Lat(), cosLat),
+				rank:     rankOf(pt),
+				seq:      i,
+			})
+		}
+	}
+
+	// heapify all accepted Points at once, then pop only the nearest
+	heap.Init(&q)
+	result := make([]Point, 0, k)
+	for len(result) < k && q.Len() > 0 {
+		result = append(result, q.PopItem().point)
+	}
+	return result
+}
@@ -0,0 +1,66 @@
+package neighborhood
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestBruteForce_Nearby(t *testing.T) {
+	idx := NewBruteForceIndex().Load(namedPoints()...)
+
+	results := idx.Nearby(NewCoordinates(-115, 45), 3, AcceptAny)
+	assertEqual(t, 3, len(results))
+	assertEqual(t, "woodinville", results[0].(*NamedPoint).Name)
+	assertEqual(t, "seattle", results[1].(*NamedPoint).Name)
+	assertEqual(t, "memphis", results[2].(*NamedPoint).Name)
+
+	// across the date line
+	results = idx.Nearby(NewCoordinates(-175, 60), 3, func(pt Point) bool {
+		return pt.(*NamedPoint).Name != "anchorage"
+	})
+	assertEqual(t, "eastrussia", results[0].(*NamedPoint).Name)
+	assertEqual(t, "seattle", results[1].(*NamedPoint).Name)
+	assertEqual(t, "woodinville", results[2].(*NamedPoint).Name)
+
+	assertEqual(t, 8, len(idx.Nearby(NewCoordinates(-175, 60), 10, AcceptAny)))
+	assertEqual(t, 0, len(NewBruteForceIndex().Nearby(NewCoordinates(0, 0), 10, AcceptAny)))
+}
+
+func TestBruteForce_Nearby_Ranked(t *testing.T) {
+	pts := []Point{
+		&RankedPoint{Point: points["seattle"], Name: "seattle-less-important", Rank: 1},
+		&RankedPoint{Point: points["seattle"], Name: "seattle-same-importance", Rank: 1},
+		&RankedPoint{Point: points["seattle"], Name: "seattle-more-important", Rank: 5},
+	}
+	idx := NewBruteForceIndex().Load(pts[0])
+	idx.Add(pts[1:]...)
+
+	results := idx.Nearby(NewCoordinates(-122, 47), 3, AcceptAny)
+	assertEqual(t, "seattle-more-important", results[0].(*RankedPoint).Name)
+	assertEqual(t, "seattle-less-important", results[1].(*RankedPoint).Name)
+	assertEqual(t, "seattle-same-importance", results[2].(*RankedPoint).Name)
+}
+
+func TestBruteForce_Nearby_MatchesKDTree(t *testing.T) {
+	rnd := rand.New(rand.NewSource(48))
+	pts := make([]Point, 2_000)
+	for i := range pts {
+		// duplicate locations with a few ranks, to exercise every tie breaker
+		pts[i] = &RankedPoint{
+			Point: NewCoordinates(float64(rnd.Intn(36)*10-180), float64(rnd.Intn(18)*10-90)),
+			Rank:  float64(rnd.Intn(3)),
+		}
+	}
+	brute := NewBruteForceIndex().Load(pts...)
+	kd := NewKDTreeIndex(KDTreeOptions{NodeSize: 4}).Load(pts...)
+
+	for trial := 0; trial < 20; trial++ {
+		origin := NewCoordinates(rnd.Float64()*360-180, rnd.Float64()*180-90)
+		accept := func(pt Point) bool { return pt.(*RankedPoint).Rank != 1 || trial%2 == 0 }
+		expected, results := kd.Nearby(origin, 50, accept), brute.Nearby(origin, 50, accept)
+		assertEqual(t, len(expected), len(results))
+		for i := range expected {
+			assertEqual(t, expected[i], results[i])
+		}
+	}
+}
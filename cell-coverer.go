@@ -0,0 +1,137 @@
+package neighborhood
+
+import (
+	"math"
+	"sort"
+)
+
+// CellCoverer approximates regions with sets of cells (see CellID). A covering contains every location of the
+// region, and may contain some locations outside of it.
+type CellCoverer struct {
+	// MinLevel is the level of the largest cells in a covering
+	MinLevel int
+	// MaxLevel is the level of the smallest cells in a covering
+	MaxLevel int
+	// MaxCells is the number of cells that a covering should not exceed. Coverings use more cells if the region
+	// overlaps more cells at MinLevel (or more cube faces).
+	MaxCells int
+}
+
+// DefaultCellCoverer gets a CellCoverer with default options, which you can use directly or modify
+func DefaultCellCoverer() CellCoverer {
+	return CellCoverer{
+		MinLevel: 0,
+		MaxLevel: MaxCellLevel,
+		MaxCells: 8,
+	}
+}
+
+// CoverCircle gets the cells that cover a circle around a center, with a radius in kilometers
+func (cc CellCoverer) CoverCircle(center Point, radiusKm float64) []CellID {
+	v := toVec3(center.Lon(), center.Lat())
+	radius := radiusKm / earthRadiusKm
+	return cc.cover(func(c CellID) (intersects, contains bool) {
+		cellCenter, cellRadius := c.capBound()
+		d := angle(v, cellCenter)
+		return d-cellRadius <= radius, d+cellRadius <= radius
+	})
+}
+
+// CoverRect gets the cells that cover a bounding box. If minLon is greater than maxLon, the box crosses the date line.
+func (cc CellCoverer) CoverRect(minLon, minLat, maxLon, maxLat float64) []CellID {
+	rects := []bounds{{MinLon: minLon, MinLat: minLat, MaxLon: maxLon, MaxLat: maxLat}}
+	if minLon > maxLon {
+		rects = []bounds{
+			{MinLon: minLon, MinLat: minLat, MaxLon: 180, MaxLat: maxLat},
+			{MinLon: -180, MinLat: minLat, MaxLon: maxLon, MaxLat: maxLat},
+		}
+	}
+	return cc.cover(func(c CellID) (intersects, contains bool) {
+		// compare the box with the boxes that bound the cell
+		contains = true
+		for _, cell := range cellRects(c) {
+			inside := false
+			for _, r := range rects {
+				if cell.MinLon <= r.MaxLon && cell.MaxLon >= r.MinLon && cell.MinLat <= r.MaxLat && cell.MaxLat >= r.MinLat {
+					intersects = true
+				}
+				if cell.MinLon >= r.MinLon && cell.MaxLon <= r.MaxLon && cell.MinLat >= r.MinLat && cell.MaxLat <= r.MaxLat {
+					inside = true
+				}
+			}
+			contains = contains && inside
+		}
+		return intersects, contains
+	})
+}
+
+// cover gets the cells that cover a region, given a function that checks whether a cell intersects or is contained
+// by the region. The checks may be conservative: a cell that does not intersect the region may be included, and a
+// cell that is contained by the region may be divided.
+func (cc CellCoverer) cover(check func(c CellID) (intersects, contains bool)) []CellID {
+	minLevel := int(math.Max(0, math.Min(float64(cc.MinLevel), MaxCellLevel)))
+	maxLevel := int(math.Max(float64(minLevel), math.Min(float64(cc.MaxLevel), MaxCellLevel)))
+
+	// candidates are cells that intersect the region, which are divided largest first while the covering fits
+	var covering, candidates []CellID
+	for face := 0; face < 6; face++ {
+		candidates = append(candidates, CellIDFromFace(face))
+	}
+	for len(candidates) > 0 {
+		c := candidates[0]
+		candidates = candidates[1:]
+		intersects, contains := check(c)
+		if !intersects {
+			continue
+		}
+		if c.Level() >= minLevel && (contains || c.Level() == maxLevel) {
+			covering = append(covering, c)
+			continue
+		}
+
+		var children []CellID
+		for _, child := range c.Children() {
+			if intersects, _ := check(child); intersects {
+				children = append(children, child)
+			}
+		}
+		if c.Level() >= minLevel && len(covering)+len(candidates)+len(children) > cc.MaxCells {
+			// dividing the cell would make too many cells
+			covering = append(covering, c)
+			continue
+		}
+		candidates = append(candidates, children...)
+	}
+
+	sort.Slice(covering, func(i, j int) bool { return covering[i] < covering[j] })
+	return covering
+}
+
+// cellRects gets bounding boxes of a cell from its bounding cap, split at the date line
+func cellRects(c CellID) []bounds {
+	center, radius := c.capBound()
+	lon, lat := center.lonLat()
+	radiusDeg := radius / rad
+	minLat, maxLat := lat-radiusDeg, lat+radiusDeg
+	if minLat <= -90 || maxLat >= 90 {
+		// the cap contains a pole, so it covers every longitude
+		return []bounds{{MinLon: -180, MinLat: math.Max(minLat, -90), MaxLon: 180, MaxLat: math.Min(maxLat, 90)}}
+	}
+
+	// the widest longitude span of a cap is at the latitude where its edge is tangent to a meridian
+	dLon := math.Asin(math.Min(1, math.Sin(radius)/math.Cos(lat*rad))) / rad
+	minLon, maxLon := lon-dLon, lon+dLon
+	switch {
+	case minLon < -180:
+		return []bounds{
+			{MinLon: minLon + 360, MinLat: minLat, MaxLon: 180, MaxLat: maxLat},
+			{MinLon: -180, MinLat: minLat, MaxLon: maxLon, MaxLat: maxLat},
+		}
+	case maxLon > 180:
+		return []bounds{
+			{MinLon: minLon, MinLat: minLat, MaxLon: 180, MaxLat: maxLat},
+			{MinLon: -180, MinLat: minLat, MaxLon: maxLon - 360, MaxLat: maxLat},
+		}
+	}
+	return []bounds{{MinLon: minLon, MinLat: minLat, MaxLon: maxLon, MaxLat: maxLat}}
+}
@@ -0,0 +1,87 @@
+package neighborhood
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestCellCoverer_CoverCircle(t *testing.T) {
+	rnd := rand.New(rand.NewSource(46))
+	cc := DefaultCellCoverer()
+	for _, center := range []Point{points["seattle"], NewCoordinates(0, 90), NewCoordinates(180, -30)} {
+		for _, radiusKm := range []float64{0.5, 50, 2000} {
+			covering := cc.CoverCircle(center, radiusKm)
+			assertEqual(t, true, len(covering) > 0 && len(covering) <= cc.MaxCells)
+			assertCovers(t, covering, func() Point {
+				return destination(center, rnd.Float64()*radiusKm, rnd.Float64()*360)
+			})
+
+			// the covering is not much larger than the circle
+			assertEqual(t, false, covers(covering, destination(center, 3*radiusKm+100, 0)))
+		}
+	}
+
+	cc = CellCoverer{MinLevel: 10, MaxLevel: 12, MaxCells: 1}
+	covering := cc.CoverCircle(points["seattle"], 20)
+	assertEqual(t, true, len(covering) > 1)
+	for _, c := range covering {
+		assertEqual(t, true, c.Level() >= 10 && c.Level() <= 12)
+	}
+}
+
+func TestCellCoverer_CoverRect(t *testing.T) {
+	rnd := rand.New(rand.NewSource(46))
+	cc := DefaultCellCoverer()
+	cc.MaxCells = 20
+
+	// a box in Washington state
+	covering := cc.CoverRect(-124, 46, -117, 49)
+	assertEqual(t, true, len(covering) <= cc.MaxCells)
+	assertCovers(t, covering, func() Point {
+		return NewCoordinates(-124+rnd.Float64()*7, 46+rnd.Float64()*3)
+	})
+	assertEqual(t, false, covers(covering, points["memphis"]))
+
+	// a box across the date line
+	covering = cc.CoverRect(175, -20, -175, -10)
+	assertCovers(t, covering, func() Point {
+		return NewCoordinates(wrapLon(175+rnd.Float64()*10), -20+rnd.Float64()*10)
+	})
+	assertEqual(t, false, covers(covering, NewCoordinates(0, -15)))
+
+	// a box around a pole
+	covering = cc.CoverRect(-180, 80, 180, 90)
+	assertCovers(t, covering, func() Point {
+		return NewCoordinates(rnd.Float64()*360-180, 80+rnd.Float64()*10)
+	})
+	assertEqual(t, false, covers(covering, NewCoordinates(0, 60)))
+}
+
+func assertCovers(t *testing.T, covering []CellID, random func() Point) {
+	for trial := 0; trial < 1_000; trial++ {
+		if pt := random(); !covers(covering, pt) {
+			t.Errorf("expected covering %v to cover %v", covering, pt)
+			t.FailNow()
+		}
+	}
+}
+
+func covers(covering []CellID, pt Point) bool {
+	leaf := CellIDFromPoint(pt)
+	for _, c := range covering {
+		if c.Contains(leaf) {
+			return true
+		}
+	}
+	return false
+}
+
+// destination gets the location at a distance and a bearing (in degrees clockwise from north) from an origin
+func destination(origin Point, km, bearing float64) Point {
+	d, theta := km/earthRadiusKm, bearing*rad
+	lat1, lon1 := origin.Lat()*rad, origin.Lon()*rad
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(d) + math.Cos(lat1)*math.Sin(d)*math.Cos(theta))
+	lon2 := lon1 + math.Atan2(math.Sin(theta)*math.Sin(d)*math.Cos(lat1), math.Cos(d)-math.Sin(lat1)*math.Sin(lat2))
+	return NewCoordinates(wrapLon(lon2/rad), lat2/rad)
+}
@@ -0,0 +1,331 @@
+package neighborhood
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MaxCellLevel is the level of the smallest cells, which are about a centimeter wide
+const MaxCellLevel = 30
+
+const (
+	cellFaceBits = 3
+	cellPosBits  = 2*MaxCellLevel + 1
+	cellMaxSize  = 1 << MaxCellLevel // number of leaf cells along each side of a cube face
+)
+
+// Hilbert curve orientations are combinations of swapping i and j, and inverting both of them
+const (
+	hilbertSwap   = 1
+	hilbertInvert = 2
+)
+
+var (
+	// hilbertIJToPos gets the position of a child cell along the Hilbert curve from its i and j bits (i<<1 | j)
+	// for each orientation
+	hilbertIJToPos = [4][4]int{
+		{0, 1, 3, 2}, // canonical order
+		{0, 3, 1, 2}, // axes swapped
+		{2, 3, 1, 0}, // bits inverted
+		{2, 1, 3, 0}, // swapped & inverted
+	}
+	// hilbertPosToIJ is the inverse of hilbertIJToPos
+	hilbertPosToIJ = [4][4]int{
+		{0, 1, 3, 2}, // canonical order
+		{0, 2, 3, 1}, // axes swapped
+		{3, 2, 0, 1}, // bits inverted
+		{3, 1, 0, 2}, // swapped & inverted
+	}
+	// hilbertPosToOrientation gets the change of orientation of the child cell at each position
+	hilbertPosToOrientation = [4]int{hilbertSwap, 0, 0, hilbertInvert | hilbertSwap}
+)
+
+// CellID identifies a cell of a hierarchical grid on the sphere, laid out like the S2 geometry library: the sphere
+// is projected onto the six faces of a cube, and each face is divided into a quadtree of cells down to MaxCellLevel.
+// Cells are numbered along a Hilbert curve, so that nearby cells tend to have nearby IDs, and all cells inside a cell
+// have IDs between its RangeMin and RangeMax. The 3 highest bits are the face, followed by 2 bits for the position of
+// each level, followed by a 1 bit that marks the level.
+type CellID uint64
+
+// CellIDFromPoint gets the ID of the leaf cell (at MaxCellLevel) that contains a Point
+func CellIDFromPoint(pt Point) CellID {
+	face, u, v := xyzToFaceUV(toVec3(pt.Lon(), pt.Lat()))
+	return cellIDFromFaceIJ(face, stToIJ(uvToST(u)), stToIJ(uvToST(v)))
+}
+
+// CellIDFromFace gets the ID of the cell (at level 0) that covers one of the six cube faces
+func CellIDFromFace(face int) CellID {
+	return CellID(uint64(face)<<cellPosBits + cellLsbForLevel(0))
+}
+
+// CellIDFromToken gets a CellID from its token (see Token)
+func CellIDFromToken(token string) (CellID, error) {
+	if len(token) == 0 || len(token) > 16 {
+		return 0, fmt.Errorf("invalid cell token %q", token)
+	}
+	id, err := strconv.ParseUint(token+strings.Repeat("0", 16-len(token)), 16, 64)
+	if err != nil || !CellID(id).IsValid() {
+		return 0, fmt.Errorf("invalid cell token %q", token)
+	}
+	return CellID(id), nil
+}
+
+// IsValid checks whether the CellID identifies a cell
+func (c CellID) IsValid() bool {
+	return c.Face() < 6 && c.lsb()&0x1555555555555555 != 0
+}
+
+// Face gets the cube face (0 to 5) of the cell
+func (c CellID) Face() int {
+	return int(uint64(c) >> cellPosBits)
+}
+
+// Level gets the level of the cell, from 0 for a cube face to MaxCellLevel for a leaf cell
+func (c CellID) Level() int {
+	lsb := c.lsb()
+	level := MaxCellLevel
+	for lsb > 1 {
+		lsb >>= 2
+		level--
+	}
+	return level
+}
+
+// IsLeaf checks whether the cell is at MaxCellLevel
+func (c CellID) IsLeaf() bool {
+	return uint64(c)&1 != 0
+}
+
+// Parent gets the cell at a lower level that contains the cell
+func (c CellID) Parent(level int) CellID {
+	lsb := cellLsbForLevel(level)
+	return CellID(uint64(c)&-lsb | lsb)
+}
+
+// Children gets the four cells at the next level inside the cell, in Hilbert curve order.
+// Leaf cells have no children.
+func (c CellID) Children() []CellID {
+	if c.IsLeaf() {
+		return nil
+	}
+	lsb := c.lsb()
+	child := uint64(c) - lsb + lsb>>2
+	children := make([]CellID, 4)
+	for i := range children {
+		children[i] = CellID(child)
+		child += lsb >> 1
+	}
+	return children
+}
+
+// RangeMin gets the lowest leaf CellID inside the cell
+func (c CellID) RangeMin() CellID {
+	return CellID(uint64(c) - (c.lsb() - 1))
+}
+
+// RangeMax gets the highest leaf CellID inside the cell
+func (c CellID) RangeMax() CellID {
+	return CellID(uint64(c) + (c.lsb() - 1))
+}
+
+// Contains checks whether another cell is inside the cell (or is the same cell)
+func (c CellID) Contains(other CellID) bool {
+	return other >= c.RangeMin() && other <= c.RangeMax()
+}
+
+// Center gets the center of the cell
+func (c CellID) Center() Coordinates {
+	lon, lat := c.centerVec().lonLat()
+	return Coordinates{lon: lon, lat: lat}
+}
+
+// Vertices gets the four corners of the cell, counterclockwise
+func (c CellID) Vertices() [4]Coordinates {
+	var vertices [4]Coordinates
+	for i, v := range c.vertexVecs() {
+		lon, lat := v.lonLat()
+		vertices[i] = Coordinates{lon: lon, lat: lat}
+	}
+	return vertices
+}
+
+// Token gets a compact string for the CellID: its hexadecimal value without trailing zeros
+func (c CellID) Token() string {
+	if c == 0 {
+		return "X"
+	}
+	return strings.TrimRight(fmt.Sprintf("%016x", uint64(c)), "0")
+}
+
+// String gets the face and Hilbert curve positions of the cell, like "2/0312"
+func (c CellID) String() string {
+	if !c.IsValid() {
+		return "Invalid: " + strconv.FormatUint(uint64(c), 16)
+	}
+	var b strings.Builder
+	b.WriteString(strconv.Itoa(c.Face()))
+	b.WriteByte('/')
+	for level := 1; level <= c.Level(); level++ {
+		b.WriteByte(byte('0' + c.childPosition(level)))
+	}
+	return b.String()
+}
+
+// lsb gets the lowest set bit, which marks the level of the cell
+func (c CellID) lsb() uint64 {
+	return uint64(c) & -uint64(c)
+}
+
+// childPosition gets the Hilbert curve position (0 to 3) of the cell's ancestor at a level within its parent
+func (c CellID) childPosition(level int) int {
+	return int(uint64(c)>>uint(cellPosBits-2*level)) & 3
+}
+
+// faceIJ gets the face of the cell and the i and j coordinates of its lower left corner, in leaf cells
+func (c CellID) faceIJ() (face, i, j int) {
+	face = c.Face()
+	orientation := face & hilbertSwap
+	level := c.Level()
+	for l := 1; l <= level; l++ {
+		pos := c.childPosition(l)
+		ij := hilbertPosToIJ[orientation][pos]
+		i = i<<1 | ij>>1
+		j = j<<1 | ij&1
+		orientation ^= hilbertPosToOrientation[pos]
+	}
+	return face, i << uint(MaxCellLevel-level), j << uint(MaxCellLevel-level)
+}
+
+// centerVec gets the center of the cell as a unit vector
+func (c CellID) centerVec() vec3 {
+	face, i, j := c.faceIJ()
+	half := float64(int(1)<<uint(MaxCellLevel-c.Level())) / 2
+	s, t := (float64(i)+half)/cellMaxSize, (float64(j)+half)/cellMaxSize
+	return faceUVToXYZ(face, stToUV(s), stToUV(t)).normalize()
+}
+
+// vertexVecs gets the corners of the cell as unit vectors
+func (c CellID) vertexVecs() [4]vec3 {
+	face, i, j := c.faceIJ()
+	size := int(1) << uint(MaxCellLevel-c.Level())
+	s0, t0 := float64(i)/cellMaxSize, float64(j)/cellMaxSize
+	s1, t1 := float64(i+size)/cellMaxSize, float64(j+size)/cellMaxSize
+	return [4]vec3{
+		faceUVToXYZ(face, stToUV(s0), stToUV(t0)).normalize(),
+		faceUVToXYZ(face, stToUV(s1), stToUV(t0)).normalize(),
+		faceUVToXYZ(face, stToUV(s1), stToUV(t1)).normalize(),
+		faceUVToXYZ(face, stToUV(s0), stToUV(t1)).normalize(),
+	}
+}
+
+// capBound gets the center of the cell and the angle (in radians) from it to the farthest point of the cell.
+// Cell edges are great circles, so the farthest point is one of the corners.
+func (c CellID) capBound() (center vec3, radius float64) {
+	center = c.centerVec()
+	for _, v := range c.vertexVecs() {
+		radius = math.Max(radius, angle(center, v))
+	}
+	// pad for rounding errors, so that the cap always contains the cell
+	return center, radius + 1e-12
+}
+
+// cellIDFromFaceIJ gets the ID of the leaf cell at given coordinates of a face
+func cellIDFromFaceIJ(face, i, j int) CellID {
+	pos := hilbertPos(face&hilbertSwap, i, j, MaxCellLevel)
+	return CellID(uint64(face)<<cellPosBits | pos<<1 | 1)
+}
+
+// hilbertPos gets the position of the coordinates i and j along a Hilbert curve that starts with an orientation,
+// and divides each axis into 2^order steps
+func hilbertPos(orientation, i, j, order int) uint64 {
+	pos := uint64(0)
+	for k := order - 1; k >= 0; k-- {
+		ij := (i>>uint(k)&1)<<1 | j>>uint(k)&1
+		p := hilbertIJToPos[orientation][ij]
+		pos = pos<<2 | uint64(p)
+		orientation ^= hilbertPosToOrientation[p]
+	}
+	return pos
+}
+
+// cellLsbForLevel gets the lowest set bit of the cells at a level
+func cellLsbForLevel(level int) uint64 {
+	return 1 << uint(2*(MaxCellLevel-level))
+}
+
+// xyzToFaceUV projects a vector onto the cube face with the largest component
+func xyzToFaceUV(v vec3) (face int, u, w float64) {
+	ax, ay, az := math.Abs(v.x), math.Abs(v.y), math.Abs(v.z)
+	switch {
+	case ax >= ay && ax >= az:
+		face = 0
+		if v.x < 0 {
+			face = 3
+		}
+	case ay >= az:
+		face = 1
+		if v.y < 0 {
+			face = 4
+		}
+	default:
+		face = 2
+		if v.z < 0 {
+			face = 5
+		}
+	}
+	switch face {
+	case 0:
+		return face, v.y / v.x, v.z / v.x
+	case 1:
+		return face, -v.x / v.y, v.z / v.y
+	case 2:
+		return face, -v.x / v.z, -v.y / v.z
+	case 3:
+		return face, v.z / v.x, v.y / v.x
+	case 4:
+		return face, v.z / v.y, -v.x / v.y
+	default:
+		return face, -v.y / v.z, -v.x / v.z
+	}
+}
+
+// faceUVToXYZ gets the (not normalized) vector of a location on a cube face
+func faceUVToXYZ(face int, u, v float64) vec3 {
+	switch face {
+	case 0:
+		return vec3{1, u, v}
+	case 1:
+		return vec3{-u, 1, v}
+	case 2:
+		return vec3{-u, -v, 1}
+	case 3:
+		return vec3{-1, -v, -u}
+	case 4:
+		return vec3{v, -1, -u}
+	default:
+		return vec3{v, u, -1}
+	}
+}
+
+// uvToST applies the quadratic transform that makes cells of the same level closer in area
+func uvToST(u float64) float64 {
+	if u >= 0 {
+		return 0.5 * math.Sqrt(1+3*u)
+	}
+	return 1 - 0.5*math.Sqrt(1-3*u)
+}
+
+// stToUV is the inverse of uvToST
+func stToUV(s float64) float64 {
+	if s >= 0.5 {
+		return (4*s*s - 1) / 3
+	}
+	return (1 - 4*(1-s)*(1-s)) / 3
+}
+
+// stToIJ gets the leaf cell coordinate of a location on a face
+func stToIJ(s float64) int {
+	return int(math.Max(0, math.Min(cellMaxSize-1, math.Floor(cellMaxSize*s))))
+}
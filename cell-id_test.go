@@ -0,0 +1,101 @@
+package neighborhood
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestCellIDFromPoint(t *testing.T) {
+	// same IDs as the S2 geometry library
+	assertEqual(t, "1000000000000001", CellIDFromPoint(NewCoordinates(0, 0)).Token())
+	nyc := CellIDFromPoint(NewCoordinates(-74.006, 40.7128))
+	assertEqual(t, "89c25", nyc.Parent(8).Token())
+	assertEqual(t, "4/10320102", nyc.Parent(8).String())
+	assertEqual(t, MaxCellLevel, nyc.Level())
+	assertEqual(t, true, nyc.IsLeaf())
+	assertEqual(t, true, distanceKm(NewCoordinates(-74.006, 40.7128), nyc.Center()) < 0.0001)
+
+	for face, token := range []string{"1", "3", "5", "7", "9", "b"} {
+		assertEqual(t, token, CellIDFromFace(face).Token())
+		assertEqual(t, 0, CellIDFromFace(face).Level())
+	}
+	assertEqual(t, 2, CellIDFromPoint(NewCoordinates(0, 90)).Face())
+	assertEqual(t, 5, CellIDFromPoint(NewCoordinates(0, -90)).Face())
+	assertEqual(t, 3, CellIDFromPoint(NewCoordinates(180, 0)).Face())
+	assertEqual(t, true, distanceKm(CellIDFromPoint(NewCoordinates(180, 0)).Center(), CellIDFromPoint(NewCoordinates(-180, 0)).Center()) < 0.00001)
+}
+
+func TestCellIDFromToken(t *testing.T) {
+	c, err := CellIDFromToken("89c25")
+	assertNil(t, err)
+	assertEqual(t, 8, c.Level())
+	assertEqual(t, "89c25", c.Token())
+
+	for _, token := range []string{"", "X", "89c2", "zz", "f", "10000000000000000"} {
+		_, err = CellIDFromToken(token)
+		assertEqual(t, true, err != nil)
+	}
+	assertEqual(t, "X", CellID(0).Token())
+	assertEqual(t, "Invalid: 0", CellID(0).String())
+}
+
+func TestCellID_Hierarchy(t *testing.T) {
+	rnd := rand.New(rand.NewSource(46))
+	for trial := 0; trial < 100; trial++ {
+		pt := NewCoordinates(rnd.Float64()*360-180, rnd.Float64()*180-90)
+		leaf := CellIDFromPoint(pt)
+		for level := 0; level < MaxCellLevel; level++ {
+			parent := leaf.Parent(level)
+			assertEqual(t, true, parent.IsValid())
+			assertEqual(t, level, parent.Level())
+			assertEqual(t, true, parent.Contains(leaf))
+
+			// exactly one child contains the leaf, and the children divide the range of the parent
+			children := parent.Children()
+			assertEqual(t, parent.RangeMin(), children[0].RangeMin())
+			assertEqual(t, parent.RangeMax(), children[3].RangeMax())
+			found := 0
+			for _, child := range children {
+				assertEqual(t, parent, child.Parent(level))
+				if child.Contains(leaf) {
+					found++
+				}
+			}
+			assertEqual(t, 1, found)
+
+			// the bounding cap contains the Point and the corners
+			center, radius := parent.capBound()
+			assertEqual(t, true, angle(center, toVec3(pt.Lon(), pt.Lat())) <= radius)
+			for _, v := range parent.Vertices() {
+				assertEqual(t, true, angle(center, toVec3(v.Lon(), v.Lat())) <= radius)
+			}
+		}
+		assertEqual(t, 0, len(leaf.Children()))
+	}
+}
+
+func TestCellID_HilbertCurve(t *testing.T) {
+	// consecutive cells of a level are always next to each other
+	for _, level := range []int{1, 3, 5} {
+		lsb := cellLsbForLevel(level)
+		for face := 0; face < 6; face++ {
+			c := CellIDFromFace(face).Children()[0]
+			for c.Level() < level {
+				c = c.Children()[0]
+			}
+			for next := CellID(uint64(c) + 2*lsb); CellIDFromFace(face).Contains(next); c, next = next, CellID(uint64(next)+2*lsb) {
+				_, i1, j1 := c.faceIJ()
+				_, i2, j2 := next.faceIJ()
+				steps := abs(i1-i2) + abs(j1-j2)
+				assertEqual(t, 1<<uint(MaxCellLevel-level), steps)
+			}
+		}
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,157 @@
+package neighborhood
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// CellIndex implements the Index interface with the leaf cell IDs (see CellID) of the Points, in sorted order.
+// The Points inside any cell are a contiguous run of the sorted IDs, so searches divide cells down from the cube
+// faces, closest cells first, and find their Points with binary searches.
+type CellIndex struct {
+	sync.RWMutex
+	nodeSize int
+	points   []Point
+	ids      []int    // indexes of the Points, sorted by cell ID
+	cellIDs  []CellID // leaf cell IDs of the Points, sorted
+}
+
+// CellIndexOptions defines configurable options for the CellIndex
+type CellIndexOptions struct {
+	// NodeSize is the number of Points in a cell below which the cell is not divided further during searches
+	NodeSize int
+}
+
+// DefaultCellIndexOptions gets the default CellIndex options, which you can use directly or modify before creating
+// an Index
+func DefaultCellIndexOptions() CellIndexOptions {
+	return CellIndexOptions{
+		NodeSize: 64,
+	}
+}
+
+// NewCellIndex creates a new CellIndex Index implementation with given CellIndexOptions
+func NewCellIndex(opts CellIndexOptions) Index {
+	return &CellIndex{
+		nodeSize: opts.NodeSize,
+	}
+}
+
+// Load adds searchable Points to the Index.
+// Each call to Load will replace all Points in the Index with the provided Points.
+// Load mutates and returns the Index to allow call chaining.
+func (idx *CellIndex) Load(points ...Point) Index {
+	idx.Lock()
+	defer idx.Unlock()
+
+	cellIDs := make([]CellID, len(points))
+	idx.ids = make([]int, len(points))
+	for i, pt := range points {
+		cellIDs[i] = CellIDFromPoint(pt)
+		idx.ids[i] = i
+	}
+	sort.SliceStable(idx.ids, func(i, j int) bool { return cellIDs[idx.ids[i]] < cellIDs[idx.ids[j]] })
+
+	idx.cellIDs = make([]CellID, len(points))
+	for i, id := range idx.ids {
+		idx.cellIDs[i] = cellIDs[id]
+	}
+	idx.points = points
+	return idx
+}
+
+// Add will update the index with the provided points, while persisting the existing points.
+// Add returns the Index after it is complete to allow call chaining. Add is as-expensive as Load
+func (idx *CellIndex) Add(points ...Point) Index {
+	return idx.Load(append(idx.points, points...)...)
+}
+
+// Nearby finds the k nearest Points to the origin that meet the Accepter criteria.
+// If there are multiple Points that are the same distance from the origin and the Points implement the Ranker
+// interface, the higher ranking Points will be preferred. Points with the same distance and rank are returned in the
+// order they were loaded (by Load, followed by Add). Nearby may return less than k results if it cannot find k
+// Points in the Index that meet the Accepter criteria.
+func (idx *CellIndex) Nearby(origin Point, k int, accept Accepter) []Point {
+	idx.RLock()
+	defer idx.RUnlock()
+
+	result := make([]Point, 0, k)
+	v := toVec3(origin.Lon(), origin.Lat())
+	cosLat := math.Cos(origin.Lat() * rad)
+
+	// queue the cells that hold Points, with the lower bound of the distance to their bounding caps
+	q := newPriorityQueue(k)
+	pushCell := func(c CellID) {
+		node := idx.cellNode(c)
+		if node.Left > node.Right {
+			return
+		}
+		center, radius := c.capBound()
+		q.PushNodeDist(node, haverSin(math.Max(0, angle(v, center)-radius)))
+	}
+	for face := 0; face < 6; face++ {
+		pushCell(CellIDFromFace(face))
+	}
+
+	for len(result) < k {
+		itm := q.PopItem()
+		if itm == nil {
+			break
+		}
+		if itm.point != nil {
+			result = append(result, itm.point)
+			continue
+		}
+
+		node := itm.node.(*cellNode)
+		if node.Right-node.Left >= idx.nodeSize && !node.Cell.IsLeaf() {
+			for _, child := range node.Cell.Children() {
+				pushCell(child)
+			}
+			continue
+		}
+		for i := node.Left; i <= node.Right; i++ {
+			if pt := idx.points[idx.ids[i]]; accept(pt) {
+				q.PushPoint(pt, haverSinDist(origin, pt.Lon(), pt.Lat(), cosLat), idx.ids[i])
+			}
+		}
+	}
+	return result
+}
+
+// InCells finds the Points inside any of the cells (like a covering from a CellCoverer) that meet the Accepter
+// criteria, in the order of the cells
+func (idx *CellIndex) InCells(cells []CellID, accept Accepter) []Point {
+	idx.RLock()
+	defer idx.RUnlock()
+
+	var result []Point
+	seen := make(map[int]bool)
+	for _, c := range cells {
+		node := idx.cellNode(c)
+		for i := node.Left; i <= node.Right; i++ {
+			// cells may overlap, but each Point is found once
+			if pt := idx.points[idx.ids[i]]; !seen[i] && accept(pt) {
+				result = append(result, pt)
+			}
+			seen[i] = true
+		}
+	}
+	return result
+}
+
+// cellNode gets the range of sorted cell IDs that are inside a cell
+func (idx *CellIndex) cellNode(c CellID) *cellNode {
+	min, max := c.RangeMin(), c.RangeMax()
+	left := sort.Search(len(idx.cellIDs), func(i int) bool { return idx.cellIDs[i] >= min })
+	right := sort.Search(len(idx.cellIDs), func(i int) bool { return idx.cellIDs[i] > max }) - 1
+	return &cellNode{Left: left, Right: right, Cell: c}
+}
+
+// cellNode defines a cell and the range of Points inside it in the CellIndex
+type cellNode struct {
+	Left  int // left index in the sorted arrays
+	Right int // right index
+	Cell  CellID
+}
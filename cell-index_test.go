@@ -0,0 +1,87 @@
+package neighborhood
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestCellIndex_Nearby(t *testing.T) {
+	idx := NewCellIndex(CellIndexOptions{NodeSize: 1}).Load(namedPoints()...)
+
+	results := idx.Nearby(NewCoordinates(-115, 45), 3, AcceptAny)
+	assertEqual(t, 3, len(results))
+	assertEqual(t, "woodinville", results[0].(*NamedPoint).Name)
+	assertEqual(t, "seattle", results[1].(*NamedPoint).Name)
+	assertEqual(t, "memphis", results[2].(*NamedPoint).Name)
+
+	// across the date line
+	results = idx.Nearby(NewCoordinates(-175, 60), 3, func(pt Point) bool {
+		return pt.(*NamedPoint).Name != "anchorage"
+	})
+	assertEqual(t, "eastrussia", results[0].(*NamedPoint).Name)
+	assertEqual(t, "seattle", results[1].(*NamedPoint).Name)
+	assertEqual(t, "woodinville", results[2].(*NamedPoint).Name)
+
+	assertEqual(t, 8, len(idx.Nearby(NewCoordinates(-175, 60), 10, AcceptAny)))
+	assertEqual(t, 0, len(NewCellIndex(DefaultCellIndexOptions()).Nearby(NewCoordinates(0, 0), 10, AcceptAny)))
+}
+
+func TestCellIndex_Nearby_Ranked(t *testing.T) {
+	pts := []Point{
+		&RankedPoint{Point: points["seattle"], Name: "seattle-less-important", Rank: 1},
+		&RankedPoint{Point: points["seattle"], Name: "seattle-same-importance", Rank: 1},
+		&RankedPoint{Point: points["seattle"], Name: "seattle-more-important", Rank: 5},
+	}
+	idx := NewCellIndex(DefaultCellIndexOptions()).Load(pts[0])
+	idx.Add(pts[1:]...)
+
+	results := idx.Nearby(NewCoordinates(-122, 47), 3, AcceptAny)
+	assertEqual(t, "seattle-more-important", results[0].(*RankedPoint).Name)
+	assertEqual(t, "seattle-less-important", results[1].(*RankedPoint).Name)
+	assertEqual(t, "seattle-same-importance", results[2].(*RankedPoint).Name)
+}
+
+func TestCellIndex_Nearby_MatchesKDTree(t *testing.T) {
+	rnd := rand.New(rand.NewSource(46))
+	pts := make([]Point, 0, 3_000)
+	for i := 0; i < 2_000; i++ {
+		pts = append(pts, &NamedPoint{Point: NewCoordinates(rnd.Float64()*360-180, rnd.Float64()*180-90)})
+	}
+	for i := 0; i < 1_000; i++ {
+		pts = append(pts, &NamedPoint{Point: NewCoordinates(-122+rnd.Float64()*0.01, 47+rnd.Float64()*0.01)})
+	}
+	cells := NewCellIndex(CellIndexOptions{NodeSize: 8}).Load(pts...)
+	kd := NewKDTreeIndex(DefaultKDTreeOptions()).Load(pts...)
+
+	origins := []Point{NewCoordinates(0, 90), NewCoordinates(180, 0), NewCoordinates(-122.005, 47.005)}
+	for trial := 0; trial < 10; trial++ {
+		origins = append(origins, NewCoordinates(rnd.Float64()*360-180, rnd.Float64()*180-90))
+	}
+	for _, origin := range origins {
+		expected, results := kd.Nearby(origin, 25, AcceptAny), cells.Nearby(origin, 25, AcceptAny)
+		assertEqual(t, len(expected), len(results))
+		for i := range expected {
+			assertEqual(t, expected[i], results[i])
+		}
+	}
+}
+
+func TestCellIndex_InCells(t *testing.T) {
+	idx := NewCellIndex(DefaultCellIndexOptions()).Load(namedPoints()...).(*CellIndex)
+
+	covering := DefaultCellCoverer().CoverCircle(points["seattle"], 50)
+	results := idx.InCells(covering, AcceptAny)
+	assertEqual(t, 2, len(results))
+
+	// overlapping cells find each Point once
+	results = idx.InCells(append(covering, CellIDFromFace(covering[0].Face())), func(pt Point) bool {
+		return pt.(*NamedPoint).Name != "seattle"
+	})
+	found := map[string]bool{}
+	for _, pt := range results {
+		assertEqual(t, false, found[pt.(*NamedPoint).Name])
+		found[pt.(*NamedPoint).Name] = true
+	}
+	assertEqual(t, false, found["seattle"])
+	assertEqual(t, true, found["woodinville"])
+}
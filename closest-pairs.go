@@ -0,0 +1,188 @@
+package neighborhood
+
+import (
+	"container/heap"
+	"math"
+)
+
+// Pair is a pair of Points and the great-circle distance between them
+type Pair struct {
+	A          Point
+	B          Point
+	DistanceKm float64
+}
+
+// ClosestPair finds the two closest Points in the Index that meet the Accepter criteria.
+// ClosestPair returns false if the Index does not have two such Points.
+func (idx *KDTree) ClosestPair(accept Accepter) (Pair, bool) {
+	pairs := idx.ClosestPairs(1, accept)
+	if len(pairs) == 0 {
+		return Pair{}, false
+	}
+	return pairs[0], true
+}
+
+// ClosestPairs finds the k closest pairs of Points in the Index that meet the Accepter criteria, closest first.
+// Each pair of Points is only returned once, with A loaded before B. Pairs at the same distance are returned in the
+// order their Points were loaded. ClosestPairs may return less than k pairs if the Index does not have enough Points
+// that meet the Accepter criteria.
+func (idx *KDTree) ClosestPairs(k int, accept Accepter) []Pair {
+	idx.RLock()
+	defer idx.RUnlock()
+
+	var result []Pair
+	if k <= 0 {
+		return result
+	}
+
+	// a distance-sorted queue that will contain both pairs of points and pairs of kd-tree nodes;
+	// a pair of nodes covers every pair of points with one point in each node
+	q := &pairQueue{}
+	root := idx.root()
+	q.push(root, root, boxBoxDist(root.bounds, root.bounds))
+
+	for q.Len() > 0 {
+		itm := heap.Pop(q).(*pairItem)
+		if itm.a == nil {
+			// pairs of points are guaranteed to be closer than all remaining pairs,
+			// since each node pair's distance is a lower bound of distances between its points
+			result = append(result, Pair{
+				A:          idx.points[idx.ids[itm.i]],
+				B:          idx.points[idx.ids[itm.j]],
+				DistanceKm: haverSinToKm(itm.distance),
+			})
+			if len(result) == k {
+				return result
+			}
+			continue
+		}
+		idx.expandPair(q, itm.a, itm.b, accept)
+	}
+	return result
+}
+
+// expandPair pushes the pairs that make up a pair of nodes into the queue
+func (idx *KDTree) expandPair(q *pairQueue, a, b *kdTreeNode, accept Accepter) {
+	if a == b {
+		if idx.isLeaf(a) {
+			// pairs of points within a single leaf node
+			for i := a.Left; i <= a.Right; i++ {
+				for j := i + 1; j <= a.Right; j++ {
+					idx.pushPointPair(q, i, j, accept)
+				}
+			}
+			return
+		}
+		// pairs within each half, pairs across the halves, and pairs with the middle point
+		m, leftNode, rightNode := idx.split(a)
+		mid := idx.pointNode(m)
+		idx.pushNodePair(q, leftNode, leftNode)
+		idx.pushNodePair(q, rightNode, rightNode)
+		idx.pushNodePair(q, leftNode, rightNode)
+		idx.pushNodePair(q, mid, leftNode)
+		idx.pushNodePair(q, mid, rightNode)
+		return
+	}
+
+	if idx.isLeaf(a) && idx.isLeaf(b) {
+		// pairs of points across two leaf nodes
+		for i := a.Left; i <= a.Right; i++ {
+			for j := b.Left; j <= b.Right; j++ {
+				idx.pushPointPair(q, i, j, accept)
+			}
+		}
+		return
+	}
+
+	// split the larger node that can be split
+	if idx.isLeaf(a) || (!idx.isLeaf(b) && b.Right-b.Left > a.Right-a.Left) {
+		a, b = b, a
+	}
+	m, leftNode, rightNode := idx.split(a)
+	idx.pushNodePair(q, leftNode, b)
+	idx.pushNodePair(q, rightNode, b)
+	idx.pushNodePair(q, idx.pointNode(m), b)
+}
+
+// pushNodePair pushes a pair of nodes into the queue, ignoring empty nodes
+func (idx *KDTree) pushNodePair(q *pairQueue, a, b *kdTreeNode) {
+	if a.Right < a.Left || b.Right < b.Left {
+		return
+	}
+	q.push(a, b, boxBoxDist(a.bounds, b.bounds))
+}
+
+// pushPointPair pushes a pair of points into the queue if both points meet the Accepter criteria
+func (idx *KDTree) pushPointPair(q *pairQueue, i, j int, accept Accepter) {
+	if !accept(idx.points[idx.ids[i]]) || !accept(idx.points[idx.ids[j]]) {
+		return
+	}
+	lon, lat := idx.coords[2*j], idx.coords[2*j+1]
+	origin := Coordinates{lon: idx.coords[2*i], lat: idx.coords[2*i+1]}
+	dist := haverSinDist(origin, lon, lat, math.Cos(origin.lat*rad))
+	if idx.ids[i] > idx.ids[j] {
+		i, j = j, i // the earlier loaded point goes first
+	}
+	heap.Push(q, &pairItem{i: i, j: j, seqs: [2]int{idx.ids[i], idx.ids[j]}, distance: dist})
+}
+
+// pointNode gets a leaf node that holds a single point
+func (idx *KDTree) pointNode(i int) *kdTreeNode {
+	lon, lat := idx.coords[2*i], idx.coords[2*i+1]
+	return &kdTreeNode{
+		Left:   i,
+		Right:  i,
+		bounds: bounds{MinLon: lon, MinLat: lat, MaxLon: lon, MaxLat: lat},
+	}
+}
+
+// pairItem is either a pair of kd-tree nodes or a pair of points (when the nodes are nil)
+type pairItem struct {
+	a, b     *kdTreeNode
+	i, j     int    // kd-tree array indices of the points
+	seqs     [2]int // insertion order of the points
+	distance float64
+}
+
+// pairQueue implements heap.Interface and holds pairItems
+type pairQueue []*pairItem
+
+// push adds a pair of nodes, loosening its distance like the priorityQueue does (see loosen)
+func (pq *pairQueue) push(a, b *kdTreeNode, dist float64) {
+	heap.Push(pq, &pairItem{a: a, b: b, distance: loosen(dist)})
+}
+
+//
+// heap.Interface implementation
+//
+
+func (pq pairQueue) Less(i, j int) bool {
+	a, b := pq[i], pq[j]
+	if a.distance != b.distance {
+		return a.distance < b.distance
+	}
+	// Pop node pairs before point pairs at equal distances, so that all tied pairs are queued before any is popped
+	if (a.a != nil) != (b.a != nil) {
+		return a.a != nil
+	}
+	// Pop the earliest inserted points (tie breaker), so results never depend on the kd-tree layout
+	if a.seqs[0] != b.seqs[0] {
+		return a.seqs[0] < b.seqs[0]
+	}
+	return a.seqs[1] < b.seqs[1]
+}
+
+func (pq pairQueue) Len() int { return len(pq) }
+
+func (pq pairQueue) Swap(i, j int) { pq[i], pq[j] = pq[j], pq[i] }
+
+func (pq *pairQueue) Push(x interface{}) { *pq = append(*pq, x.(*pairItem)) }
+
+func (pq *pairQueue) Pop() interface{} {
+	old := *pq
+	n := len(old)
+	itm := old[n-1]
+	old[n-1] = nil // avoid memory leak
+	*pq = old[0 : n-1]
+	return itm
+}
@@ -0,0 +1,123 @@
+package neighborhood
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestKDTree_ClosestPair(t *testing.T) {
+	idx := NewIndex().Load(namedPoints()...).(*KDTree)
+
+	pair, ok := idx.ClosestPair(AcceptAny)
+	assertEqual(t, true, ok)
+	names := []string{pair.A.(*NamedPoint).Name, pair.B.(*NamedPoint).Name}
+	sort.Strings(names)
+	assertEqual(t, "seattle", names[0])
+	assertEqual(t, "woodinville", names[1])
+	assertEqual(t, 24, int(pair.DistanceKm))
+}
+
+func TestKDTree_ClosestPair_AntiMeridian(t *testing.T) {
+	pts := append(namedPoints(),
+		&NamedPoint{Point: NewCoordinates(179.99, 10), Name: "date-line-west"},
+		&NamedPoint{Point: NewCoordinates(-179.99, 10), Name: "date-line-east"},
+	)
+	idx := NewKDTreeIndex(KDTreeOptions{NodeSize: 2}).Load(pts...).(*KDTree)
+
+	pair, ok := idx.ClosestPair(AcceptAny)
+	assertEqual(t, true, ok)
+	names := []string{pair.A.(*NamedPoint).Name, pair.B.(*NamedPoint).Name}
+	sort.Strings(names)
+	assertEqual(t, "date-line-east", names[0])
+	assertEqual(t, "date-line-west", names[1])
+}
+
+func TestKDTree_ClosestPair_NotEnough(t *testing.T) {
+	idx := NewIndex().Load(namedPoint("seattle")).(*KDTree)
+	_, ok := idx.ClosestPair(AcceptAny)
+	assertEqual(t, false, ok)
+
+	idx = NewIndex().(*KDTree)
+	_, ok = idx.ClosestPair(AcceptAny)
+	assertEqual(t, false, ok)
+}
+
+func TestKDTree_ClosestPairs(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	pts := make([]Point, 500)
+	for i := range pts {
+		pts[i] = NewCoordinates(rnd.Float64()*360-180, rnd.Float64()*180-90)
+	}
+	// use a small NodeSize so pairs must come from many different node pairs
+	idx := NewKDTreeIndex(KDTreeOptions{NodeSize: 4}).Load(pts...).(*KDTree)
+
+	// compare with the distances of all pairs
+	var expected []float64
+	for i := range pts {
+		for j := i + 1; j < len(pts); j++ {
+			expected = append(expected, distanceKm(pts[i], pts[j]))
+		}
+	}
+	sort.Float64s(expected)
+
+	pairs := idx.ClosestPairs(50, AcceptAny)
+	assertEqual(t, 50, len(pairs))
+	for i, pair := range pairs {
+		assertEqual(t, int(expected[i]*1000), int(pair.DistanceKm*1000))
+		assertEqual(t, int(distanceKm(pair.A, pair.B)*1000), int(pair.DistanceKm*1000))
+	}
+
+	assertEqual(t, len(expected), len(idx.ClosestPairs(len(expected)+10, AcceptAny)))
+	assertEqual(t, 0, len(idx.ClosestPairs(0, AcceptAny)))
+}
+
+func TestKDTree_ClosestPairs_Ties(t *testing.T) {
+	// Points at the same location are tied at zero distance (while the two south pole locations are a tiny distance
+	// apart, because of rounding)
+	locations := []Point{
+		NewCoordinates(-179.96687182147394, -90),
+		NewCoordinates(-122.3, 47.6),
+		NewCoordinates(179.9948180657775, -90),
+		NewCoordinates(139.7, 35.7),
+		NewCoordinates(0, 0),
+	}
+	pts := make([]Point, 30)
+	for i := range pts {
+		pts[i] = &NamedPoint{Point: locations[(i*7)%len(locations)], Name: fmt.Sprint(i)}
+	}
+	same := func(a, b Point) bool {
+		return a.Lon() == b.Lon() && a.Lat() == b.Lat()
+	}
+
+	// tied pairs are in the order their Points were loaded
+	var expected [][2]string
+	for i := range pts {
+		for j := i + 1; j < len(pts); j++ {
+			if same(pts[i], pts[j]) {
+				expected = append(expected, [2]string{fmt.Sprint(i), fmt.Sprint(j)})
+			}
+		}
+	}
+	idx := NewKDTreeIndex(KDTreeOptions{NodeSize: 2}).Load(pts...).(*KDTree)
+	pairs := idx.ClosestPairs(len(expected), AcceptAny)
+	assertEqual(t, len(expected), len(pairs))
+	for i, pair := range pairs {
+		assertEqual(t, 0.0, pair.DistanceKm)
+		assertEqual(t, expected[i], [2]string{pair.A.(*NamedPoint).Name, pair.B.(*NamedPoint).Name})
+	}
+}
+
+func TestKDTree_ClosestPairs_Accepter(t *testing.T) {
+	idx := NewIndex().Load(namedPoints()...).(*KDTree)
+
+	pairs := idx.ClosestPairs(3, func(pt Point) bool {
+		return pt.(*NamedPoint).Name != "woodinville"
+	})
+	assertEqual(t, 3, len(pairs))
+	for _, pair := range pairs {
+		assertEqual(t, true, pair.A.(*NamedPoint).Name != "woodinville")
+		assertEqual(t, true, pair.B.(*NamedPoint).Name != "woodinville")
+	}
+}
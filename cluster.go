@@ -0,0 +1,260 @@
+package neighborhood
+
+import (
+	"errors"
+	"math"
+	"sync"
+)
+
+// ErrClusterNotFound is returned when a cluster ID does not exist in the ClusterIndex
+var ErrClusterNotFound = errors.New("neighborhood: cluster not found")
+
+// ClusterIndex groups Points into hierarchical clusters for each map zoom level, for drawing Points on a map.
+// It keeps a kd-tree of clusters per zoom level, like Mapbox's supercluster.
+type ClusterIndex struct {
+	sync.RWMutex
+	opts     ClusterOptions
+	trees    []*KDTree  // kd-tree of visible clusters, indexed by zoom level
+	clusters []*Cluster // all clusters and leaves, indexed by ID
+}
+
+// ClusterOptions defines configurable options for the ClusterIndex
+type ClusterOptions struct {
+	MinZoom   int     // minimum zoom level to generate clusters on (at least 0)
+	MaxZoom   int     // maximum zoom level to generate clusters on (at least MinZoom); Points are never clustered above it
+	Radius    float64 // cluster radius in pixels
+	Extent    float64 // tile extent in pixels (Radius is relative to it)
+	MinPoints int     // minimum number of Points to form a cluster
+	NodeSize  int     // kd-tree node size
+}
+
+// DefaultClusterOptions gets the default ClusterIndex options, which you can use directly or modify before creating
+// a ClusterIndex
+func DefaultClusterOptions() ClusterOptions {
+	return ClusterOptions{
+		MinZoom:   0,
+		MaxZoom:   16,
+		Radius:    40,
+		Extent:    512,
+		MinPoints: 2,
+		NodeSize:  64,
+	}
+}
+
+// Cluster is a Point that represents one or more indexed Points at a zoom level.
+// A Cluster with a Count of one is a leaf that wraps a single indexed Point.
+type Cluster struct {
+	Coordinates
+	ID    int   // unique cluster ID; leaves use the index of their Point in the loaded Points
+	Count int   // number of indexed Points in the cluster
+	Point Point // the indexed Point of a leaf, or nil for clusters
+
+	zoom     int        // zoom level the cluster was formed at, or the lowest zoom level a leaf is visible at
+	children []*Cluster // clusters and leaves merged into this cluster at the next zoom level
+}
+
+// NewClusterIndex creates a new ClusterIndex with given ClusterOptions
+func NewClusterIndex(opts ClusterOptions) *ClusterIndex {
+	opts.MinZoom = int(math.Max(float64(opts.MinZoom), 0))
+	opts.MaxZoom = int(math.Max(float64(opts.MaxZoom), float64(opts.MinZoom)))
+	return &ClusterIndex{
+		opts: opts,
+	}
+}
+
+// Load clusters Points on every zoom level. Each call to Load will replace all Points in the ClusterIndex with the
+// provided Points. Load mutates and returns the ClusterIndex to allow call chaining.
+func (ci *ClusterIndex) Load(points ...Point) *ClusterIndex {
+	ci.Lock()
+	defer ci.Unlock()
+
+	ci.trees = make([]*KDTree, ci.opts.MaxZoom+2)
+	ci.clusters = make([]*Cluster, 0, len(points))
+
+	// every Point starts out as a leaf above the maximum zoom
+	level := make([]Point, len(points))
+	for i, pt := range points {
+		leaf := &Cluster{
+			Coordinates: Coordinates{lon: pt.Lon(), lat: pt.Lat()},
+			ID:          i,
+			Count:       1,
+			Point:       pt,
+			zoom:        ci.opts.MaxZoom + 1,
+		}
+		ci.clusters = append(ci.clusters, leaf)
+		level[i] = leaf
+	}
+	ci.trees[ci.opts.MaxZoom+1] = ci.newTree(level)
+
+	// cluster the previous zoom level's clusters, from the maximum zoom down to the minimum zoom
+	for z := ci.opts.MaxZoom; z >= ci.opts.MinZoom; z-- {
+		level = ci.cluster(ci.trees[z+1], z)
+		ci.trees[z] = ci.newTree(level)
+	}
+	return ci
+}
+
+// Clusters gets the clusters and leaves inside a bounding box at a zoom level. If minLon is greater than maxLon,
+// the bounding box is considered to cross the date line.
+func (ci *ClusterIndex) Clusters(minLon, minLat, maxLon, maxLat float64, zoom int) []*Cluster {
+	ci.RLock()
+	defer ci.RUnlock()
+
+	if ci.trees == nil {
+		return nil
+	}
+	tree := ci.trees[ci.limitZoom(zoom)]
+	points := tree.Range(minLon, minLat, maxLon, maxLat, AcceptAny)
+	result := make([]*Cluster, len(points))
+	for i, pt := range points {
+		result[i] = pt.(*Cluster)
+	}
+	return result
+}
+
+// Children gets the clusters and leaves a cluster expands into on the next zoom level
+func (ci *ClusterIndex) Children(clusterID int) ([]*Cluster, error) {
+	c, err := ci.get(clusterID)
+	if err != nil {
+		return nil, err
+	}
+	return append([]*Cluster(nil), c.children...), nil
+}
+
+// Leaves gets the indexed Points of a cluster, skipping the first offset Points and returning at most limit Points
+func (ci *ClusterIndex) Leaves(clusterID int, limit, offset int) ([]Point, error) {
+	c, err := ci.get(clusterID)
+	if err != nil {
+		return nil, err
+	}
+	var result []Point
+	var skipped int
+	var collect func(c *Cluster)
+	collect = func(c *Cluster) {
+		for _, child := range c.children {
+			if len(result) == limit {
+				return
+			}
+			if child.Point == nil {
+				if skipped+child.Count <= offset {
+					skipped += child.Count // skip the whole cluster
+				} else {
+					collect(child)
+				}
+			} else if skipped < offset {
+				skipped++
+			} else {
+				result = append(result, child.Point)
+			}
+		}
+	}
+	if c.Point != nil {
+		if offset == 0 && limit > 0 {
+			result = append(result, c.Point)
+		}
+		return result, nil
+	}
+	collect(c)
+	return result, nil
+}
+
+// ExpansionZoom gets the zoom level at which a cluster expands into its children.
+// Leaves never expand, so they get the zoom level they first appear at.
+func (ci *ClusterIndex) ExpansionZoom(clusterID int) (int, error) {
+	c, err := ci.get(clusterID)
+	if err != nil {
+		return 0, err
+	}
+	if c.Point != nil {
+		return c.zoom, nil
+	}
+	return c.zoom + 1, nil
+}
+
+// get gets a cluster or leaf by ID
+func (ci *ClusterIndex) get(clusterID int) (*Cluster, error) {
+	ci.RLock()
+	defer ci.RUnlock()
+
+	if clusterID < 0 || clusterID >= len(ci.clusters) {
+		return nil, ErrClusterNotFound
+	}
+	return ci.clusters[clusterID], nil
+}
+
+// cluster merges the clusters of a kd-tree into the clusters visible at a zoom level
+func (ci *ClusterIndex) cluster(tree *KDTree, zoom int) []Point {
+	var level []Point
+	// a cluster is taken once its zoom drops to the current zoom level
+	taken := make(map[*Cluster]bool)
+
+	for i := range tree.points {
+		c := tree.points[i].(*Cluster)
+		if taken[c] {
+			continue
+		}
+		taken[c] = true
+
+		// convert the pixel radius to kilometers at the cluster's latitude (web mercator)
+		pixelKm := 2 * math.Pi * earthRadiusKm * math.Cos(c.lat*rad) / (ci.opts.Extent * math.Exp2(float64(zoom)))
+		var neighbors []*Cluster
+		count := c.Count
+		tree.within(c, kmToHaverSin(ci.opts.Radius*pixelKm), func(j int) {
+			if n := tree.points[tree.ids[j]].(*Cluster); !taken[n] {
+				neighbors = append(neighbors, n)
+				count += n.Count
+			}
+		})
+
+		if count < ci.opts.MinPoints || len(neighbors) == 0 {
+			// not enough points to form a cluster, so keep them as they are
+			for _, kept := range append([]*Cluster{c}, neighbors...) {
+				taken[kept] = true
+				if kept.Point != nil {
+					kept.zoom = zoom // the leaf is still visible at this zoom level
+				}
+				level = append(level, kept)
+			}
+			continue
+		}
+
+		// form a new cluster at the weighted center of its children,
+		// measuring longitudes relative to the first child to account for the date line
+		var lonSum, latSum float64
+		children := append([]*Cluster{c}, neighbors...)
+		for _, child := range children {
+			taken[child] = true
+			lonSum += wrapLon(child.lon-c.lon) * float64(child.Count)
+			latSum += child.lat * float64(child.Count)
+		}
+		merged := &Cluster{
+			Coordinates: Coordinates{
+				lon: wrapLon(c.lon + lonSum/float64(count)),
+				lat: latSum / float64(count),
+			},
+			ID:       len(ci.clusters),
+			Count:    count,
+			zoom:     zoom,
+			children: children,
+		}
+		ci.clusters = append(ci.clusters, merged)
+		level = append(level, merged)
+	}
+	return level
+}
+
+// newTree creates a kd-tree of clusters
+func (ci *ClusterIndex) newTree(clusters []Point) *KDTree {
+	return NewKDTreeIndex(KDTreeOptions{NodeSize: ci.opts.NodeSize}).Load(clusters...).(*KDTree)
+}
+
+// limitZoom clamps a zoom level to the zoom levels that have a kd-tree
+func (ci *ClusterIndex) limitZoom(zoom int) int {
+	if zoom < ci.opts.MinZoom {
+		return ci.opts.MinZoom
+	}
+	if zoom > ci.opts.MaxZoom+1 {
+		return ci.opts.MaxZoom + 1
+	}
+	return zoom
+}
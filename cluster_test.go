@@ -0,0 +1,156 @@
+package neighborhood
+
+import "testing"
+
+func clusterTestPoints() []Point {
+	return []Point{
+		namedPoint("seattle"),
+		namedPoint("woodinville"),
+		namedPoint("memphis"),
+		namedPoint("tokyo"),
+		&NamedPoint{Point: NewCoordinates(179.9, 63), Name: "west-of-date-line"},
+		&NamedPoint{Point: NewCoordinates(-179.9, 63), Name: "east-of-date-line"},
+	}
+}
+
+func TestClusterIndex_Clusters(t *testing.T) {
+	ci := NewClusterIndex(DefaultClusterOptions()).Load(clusterTestPoints()...)
+
+	// zoomed out, the whole world fits in a single tile, so everything nearby collapses
+	clusters := ci.Clusters(-180, -90, 180, 90, 0)
+	total := 0
+	for _, c := range clusters {
+		total += c.Count
+	}
+	assertEqual(t, 6, total)
+	assertEqual(t, true, len(clusters) < 6)
+
+	// zoomed in past the maximum zoom, every point is a leaf
+	clusters = ci.Clusters(-180, -90, 180, 90, 20)
+	assertEqual(t, 6, len(clusters))
+	for _, c := range clusters {
+		assertEqual(t, 1, c.Count)
+		assertEqual(t, true, c.Point != nil)
+	}
+}
+
+func TestClusterIndex_Clusters_BoundingBox(t *testing.T) {
+	ci := NewClusterIndex(DefaultClusterOptions()).Load(clusterTestPoints()...)
+
+	clusters := ci.Clusters(-125, 45, -120, 50, 17)
+	assertEqual(t, 2, len(clusters))
+
+	// seattle and woodinville are ~24 km apart, so they are one cluster at zoom 5
+	clusters = ci.Clusters(-125, 45, -120, 50, 5)
+	assertEqual(t, 1, len(clusters))
+	assertEqual(t, 2, clusters[0].Count)
+	assertNil(t, clusters[0].Point)
+}
+
+func TestClusterIndex_AntiMeridian(t *testing.T) {
+	ci := NewClusterIndex(DefaultClusterOptions()).Load(clusterTestPoints()...)
+
+	// the two points on either side of the date line are ~10 km apart
+	clusters := ci.Clusters(179, 60, -179, 65, 6)
+	assertEqual(t, 1, len(clusters))
+	assertEqual(t, 2, clusters[0].Count)
+	assertEqual(t, true, clusters[0].Lon() > 179.9 || clusters[0].Lon() < -179.9)
+}
+
+func TestClusterIndex_Expand(t *testing.T) {
+	ci := NewClusterIndex(DefaultClusterOptions()).Load(clusterTestPoints()...)
+
+	clusters := ci.Clusters(-125, 45, -120, 50, 5)
+	assertEqual(t, 1, len(clusters))
+	id := clusters[0].ID
+
+	children, err := ci.Children(id)
+	assertNil(t, err)
+	assertEqual(t, 2, len(children))
+
+	zoom, err := ci.ExpansionZoom(id)
+	assertNil(t, err)
+	assertEqual(t, 2, len(ci.Clusters(-125, 45, -120, 50, zoom)))
+	assertEqual(t, 1, len(ci.Clusters(-125, 45, -120, 50, zoom-1)))
+
+	leaves, err := ci.Leaves(id, 10, 0)
+	assertNil(t, err)
+	assertEqual(t, 2, len(leaves))
+	for _, leaf := range leaves {
+		name := leaf.(*NamedPoint).Name
+		assertEqual(t, true, name == "seattle" || name == "woodinville")
+	}
+
+	leaves, err = ci.Leaves(id, 10, 1)
+	assertNil(t, err)
+	assertEqual(t, 1, len(leaves))
+
+	leaves, err = ci.Leaves(id, 1, 0)
+	assertNil(t, err)
+	assertEqual(t, 1, len(leaves))
+
+	// leaves get the zoom level they first appear at
+	visible := func(id, zoom int) bool {
+		for _, c := range ci.Clusters(-180, -90, 180, 90, zoom) {
+			if c.ID == id {
+				return true
+			}
+		}
+		return false
+	}
+	for _, child := range children {
+		leafZoom, err := ci.ExpansionZoom(child.ID)
+		assertNil(t, err)
+		assertEqual(t, zoom, leafZoom)
+	}
+	for id := range clusterTestPoints() {
+		leafZoom, err := ci.ExpansionZoom(id)
+		assertNil(t, err)
+		assertEqual(t, true, visible(id, leafZoom))
+		assertEqual(t, true, leafZoom == 0 || !visible(id, leafZoom-1))
+	}
+}
+
+func TestClusterIndex_Leaves_Nested(t *testing.T) {
+	ci := NewClusterIndex(DefaultClusterOptions()).Load(globalPoints(1_000)...)
+
+	for _, c := range ci.Clusters(-180, -90, 180, 90, 0) {
+		leaves, err := ci.Leaves(c.ID, c.Count, 0)
+		assertNil(t, err)
+		assertEqual(t, c.Count, len(leaves))
+
+		leaves, err = ci.Leaves(c.ID, c.Count, c.Count-1)
+		assertNil(t, err)
+		assertEqual(t, 1, len(leaves))
+	}
+}
+
+func TestClusterIndex_NotFound(t *testing.T) {
+	ci := NewClusterIndex(DefaultClusterOptions()).Load(clusterTestPoints()...)
+
+	_, err := ci.Children(-1)
+	assertEqual(t, ErrClusterNotFound, err)
+	_, err = ci.Leaves(1_000, 10, 0)
+	assertEqual(t, ErrClusterNotFound, err)
+	_, err = ci.ExpansionZoom(1_000)
+	assertEqual(t, ErrClusterNotFound, err)
+}
+
+func TestClusterIndex_InvalidZooms(t *testing.T) {
+	// zoom levels are clamped, so that Load does not panic
+	for _, zooms := range [][2]int{{-3, 16}, {10, 5}, {-1, -5}} {
+		opts := DefaultClusterOptions()
+		opts.MinZoom, opts.MaxZoom = zooms[0], zooms[1]
+		ci := NewClusterIndex(opts).Load(clusterTestPoints()...)
+		assertEqual(t, true, len(ci.Clusters(-180, -90, 180, 90, 0)) > 0)
+		assertEqual(t, len(clusterTestPoints()), len(ci.Clusters(-180, -90, 180, 90, 20)))
+	}
+}
+
+func TestClusterIndex_Empty(t *testing.T) {
+	ci := NewClusterIndex(DefaultClusterOptions())
+	assertEqual(t, 0, len(ci.Clusters(-180, -90, 180, 90, 0)))
+
+	ci.Load()
+	assertEqual(t, 0, len(ci.Clusters(-180, -90, 180, 90, 0)))
+}
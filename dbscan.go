@@ -0,0 +1,71 @@
+package neighborhood
+
+// Noise is the DBSCAN cluster label of Points that do not belong to any cluster
+const Noise = -1
+
+// unclassified marks Points that DBSCAN has not visited yet
+const unclassified = -2
+
+// DBSCAN clusters Points by density. A Point with at least minPts Points (itself included) within epsKm is a core
+// Point; clusters are grown from core Points through their neighborhoods, and Points that cannot be reached from
+// any core Point are Noise. Distances are great-circle distances, so clusters may span the date line or the poles.
+// DBSCAN gets a cluster label for each Point (in input order), with clusters numbered from 0, and the Noise Points.
+func DBSCAN(points []Point, epsKm float64, minPts int) (labels []int, noise []Point) {
+	idx := NewKDTreeIndex(DefaultKDTreeOptions()).Load(points...).(*KDTree)
+	maxDist := kmToHaverSin(epsKm)
+
+	// neighbors gets the input indices of all points within eps of the given point, including itself
+	neighbors := func(i int) []int {
+		var result []int
+		idx.within(points[i], maxDist, func(j int) {
+			result = append(result, idx.ids[j])
+		})
+		return result
+	}
+
+	labels = make([]int, len(points))
+	for i := range labels {
+		labels[i] = unclassified
+	}
+
+	cluster := 0
+	for i := range points {
+		if labels[i] != unclassified {
+			continue
+		}
+		seeds := neighbors(i)
+		if len(seeds) < minPts {
+			labels[i] = Noise // may still become a border point of a later cluster
+			continue
+		}
+
+		// expand a new cluster from the core point
+		labels[i] = cluster
+		for s := 0; s < len(seeds); s++ {
+			j := seeds[s]
+			if labels[j] == Noise {
+				labels[j] = cluster // border point
+			}
+			if labels[j] != unclassified {
+				continue
+			}
+			labels[j] = cluster
+			if more := neighbors(j); len(more) >= minPts {
+				// only points that are not in a cluster yet can be expanded
+				for _, m := range more {
+					if labels[m] == unclassified || labels[m] == Noise {
+						seeds = append(seeds, m)
+					}
+				}
+			}
+		}
+		cluster++
+	}
+
+	for i, label := range labels {
+		if label == Noise {
+			noise = append(noise, points[i])
+		}
+	}
+	return labels, noise
+}
@@ -0,0 +1,62 @@
+package neighborhood
+
+import "testing"
+
+func TestDBSCAN(t *testing.T) {
+	pts := []Point{
+		NewCoordinates(-122.40, 47.60), // seattle cluster
+		NewCoordinates(-122.41, 47.61),
+		NewCoordinates(-122.39, 47.59),
+		NewCoordinates(-90.05, 35.15), // lonely memphis
+		NewCoordinates(139.67, 35.67), // tokyo cluster
+		NewCoordinates(139.68, 35.68),
+		NewCoordinates(139.66, 35.66),
+	}
+
+	labels, noise := DBSCAN(pts, 5, 3)
+	assertEqual(t, len(pts), len(labels))
+	assertEqual(t, 0, labels[0])
+	assertEqual(t, 0, labels[1])
+	assertEqual(t, 0, labels[2])
+	assertEqual(t, Noise, labels[3])
+	assertEqual(t, 1, labels[4])
+	assertEqual(t, 1, labels[5])
+	assertEqual(t, 1, labels[6])
+	assertEqual(t, 1, len(noise))
+	assertEqual(t, pts[3], noise[0])
+}
+
+func TestDBSCAN_BorderPoint(t *testing.T) {
+	pts := []Point{
+		NewCoordinates(0.022, 0), // border point, visited first and initially noise
+		NewCoordinates(0, 0),
+		NewCoordinates(0.001, 0),
+		NewCoordinates(-0.001, 0),
+	}
+
+	// the border point is ~2.4 km from the core, but has too few neighbors of its own
+	labels, noise := DBSCAN(pts, 2.5, 4)
+	assertEqual(t, 0, labels[0])
+	assertEqual(t, 0, labels[1])
+	assertEqual(t, 0, len(noise))
+}
+
+func TestDBSCAN_AntiMeridian(t *testing.T) {
+	pts := []Point{
+		NewCoordinates(179.99, 0),
+		NewCoordinates(-179.99, 0),
+		NewCoordinates(179.995, 0.01),
+	}
+
+	labels, noise := DBSCAN(pts, 5, 3)
+	assertEqual(t, 0, labels[0])
+	assertEqual(t, 0, labels[1])
+	assertEqual(t, 0, labels[2])
+	assertEqual(t, 0, len(noise))
+}
+
+func TestDBSCAN_Empty(t *testing.T) {
+	labels, noise := DBSCAN(nil, 5, 3)
+	assertEqual(t, 0, len(labels))
+	assertEqual(t, 0, len(noise))
+}
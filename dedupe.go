@@ -0,0 +1,96 @@
+package neighborhood
+
+// Duplicates groups Points that are within toleranceKm of each other. Grouping is transitive: two Points farther
+// apart than toleranceKm end up in the same group if a chain of close Points connects them.
+// Each group keeps the input order of its Points, and groups are ordered by their first Point.
+func Duplicates(points []Point, toleranceKm float64) [][]Point {
+	idx := NewKDTreeIndex(DefaultKDTreeOptions()).Load(points...).(*KDTree)
+	maxDist := kmToHaverSin(toleranceKm)
+
+	// union every pair of close points; the kd-tree keeps each range search local
+	set := newDisjointSet(len(points))
+	for i := range idx.ids {
+		id := idx.ids[i]
+		idx.within(points[id], maxDist, func(j int) {
+			if other := idx.ids[j]; other > id {
+				set.union(id, other)
+			}
+		})
+	}
+
+	// roots are always the lowest index in their set, so groups come out in input order
+	var groups [][]Point
+	groupOf := make(map[int]int)
+	for i, pt := range points {
+		root := set.find(i)
+		g, ok := groupOf[root]
+		if !ok {
+			g = len(groups)
+			groupOf[root] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], pt)
+	}
+	return groups
+}
+
+// Dedupe gets one representative Point for each group of Duplicates. The first Point of each group is used,
+// unless preferRank is set, in which case the highest ranking Point of each group is used (see Ranker).
+func Dedupe(points []Point, toleranceKm float64, preferRank bool) []Point {
+	groups := Duplicates(points, toleranceKm)
+	result := make([]Point, 0, len(groups))
+	for _, group := range groups {
+		best := group[0]
+		if preferRank {
+			bestRank := rankOf(best)
+			for _, pt := range group[1:] {
+				if rank := rankOf(pt); rank > bestRank {
+					best, bestRank = pt, rank
+				}
+			}
+		}
+		result = append(result, best)
+	}
+	return result
+}
+
+// rankOf gets the Point rank if it implements the optional Ranker interface, or zero otherwise
+func rankOf(pt Point) float64 {
+	if ranked, ok := pt.(Ranker); ok {
+		return ranked.GetRank()
+	}
+	return 0
+}
+
+// disjointSet is a union-find structure over the indices [0..n)
+type disjointSet []int
+
+func newDisjointSet(n int) disjointSet {
+	set := make(disjointSet, n)
+	for i := range set {
+		set[i] = i
+	}
+	return set
+}
+
+// find gets the root of the set containing i, halving the path along the way
+func (set disjointSet) find(i int) int {
+	for set[i] != i {
+		set[i] = set[set[i]]
+		i = set[i]
+	}
+	return i
+}
+
+// union merges the sets containing i and j, keeping the lowest index as the root
+func (set disjointSet) union(i, j int) {
+	ri, rj := set.find(i), set.find(j)
+	if ri == rj {
+		return
+	}
+	if ri < rj {
+		set[rj] = ri
+	} else {
+		set[ri] = rj
+	}
+}
@@ -0,0 +1,62 @@
+package neighborhood
+
+import "testing"
+
+func TestDuplicates(t *testing.T) {
+	pts := []Point{
+		&NamedPoint{Point: NewCoordinates(-122.4, 47.6), Name: "seattle-1"},
+		&NamedPoint{Point: NewCoordinates(-90.05, 35.15), Name: "memphis"},
+		&NamedPoint{Point: NewCoordinates(-122.40001, 47.60001), Name: "seattle-2"},
+		&NamedPoint{Point: NewCoordinates(-122.4, 47.6), Name: "seattle-3"},
+	}
+
+	groups := Duplicates(pts, 0.01)
+	assertEqual(t, 2, len(groups))
+	assertEqual(t, 3, len(groups[0]))
+	assertEqual(t, "seattle-1", groups[0][0].(*NamedPoint).Name)
+	assertEqual(t, "seattle-2", groups[0][1].(*NamedPoint).Name)
+	assertEqual(t, "seattle-3", groups[0][2].(*NamedPoint).Name)
+	assertEqual(t, 1, len(groups[1]))
+	assertEqual(t, "memphis", groups[1][0].(*NamedPoint).Name)
+}
+
+func TestDuplicates_Transitive(t *testing.T) {
+	// each point is ~0.8 km from the next, but the ends are ~1.6 km apart
+	pts := []Point{
+		NewCoordinates(0, 0),
+		NewCoordinates(0.0072, 0),
+		NewCoordinates(0.0144, 0),
+	}
+	assertEqual(t, 1, len(Duplicates(pts, 1)))
+	assertEqual(t, 3, len(Duplicates(pts, 0.5)))
+}
+
+func TestDuplicates_AntiMeridian(t *testing.T) {
+	pts := []Point{
+		NewCoordinates(179.99999, 10),
+		NewCoordinates(-179.99999, 10),
+	}
+	assertEqual(t, 1, len(Duplicates(pts, 0.01)))
+}
+
+func TestDuplicates_Empty(t *testing.T) {
+	assertEqual(t, 0, len(Duplicates(nil, 1)))
+}
+
+func TestDedupe(t *testing.T) {
+	pts := []Point{
+		&RankedPoint{Point: points["seattle"], Name: "seattle-low", Rank: 1},
+		&RankedPoint{Point: points["seattle"], Name: "seattle-high", Rank: 5},
+		&RankedPoint{Point: points["memphis"], Name: "memphis", Rank: 0},
+	}
+
+	results := Dedupe(pts, 0.01, false)
+	assertEqual(t, 2, len(results))
+	assertEqual(t, "seattle-low", results[0].(*RankedPoint).Name)
+	assertEqual(t, "memphis", results[1].(*RankedPoint).Name)
+
+	results = Dedupe(pts, 0.01, true)
+	assertEqual(t, 2, len(results))
+	assertEqual(t, "seattle-high", results[0].(*RankedPoint).Name)
+	assertEqual(t, "memphis", results[1].(*RankedPoint).Name)
+}
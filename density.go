@@ -0,0 +1,106 @@
+package neighborhood
+
+import "math"
+
+// Kernel defines how much a Point contributes to a density estimate, based on its distance from the location
+type Kernel int
+
+const (
+	// GaussianKernel weighs Points by a normal distribution of their distance. Points farther than
+	// gaussianSupport bandwidths contribute almost nothing and are ignored.
+	GaussianKernel Kernel = iota
+	// EpanechnikovKernel weighs Points by one minus their squared distance in bandwidths.
+	// Points farther than one bandwidth are ignored.
+	EpanechnikovKernel
+)
+
+// gaussianSupport is the number of bandwidths after which Gaussian kernel contributions are ignored
+const gaussianSupport = 4.0
+
+// DensityOptions defines configurable options for density estimates
+type DensityOptions struct {
+	Kernel      Kernel
+	BandwidthKm float64
+}
+
+// DefaultDensityOptions gets the default density options, which you can use directly or modify
+func DefaultDensityOptions() DensityOptions {
+	return DensityOptions{
+		Kernel:      GaussianKernel,
+		BandwidthKm: 50,
+	}
+}
+
+// Density estimates the density of Points (per square kilometer) at a location, using a kernel density estimate
+// with great-circle distances. Only Points within the kernel's support are visited.
+func (idx *KDTree) Density(at Point, opts DensityOptions) float64 {
+	idx.RLock()
+	defer idx.RUnlock()
+
+	return idx.density(at, opts)
+}
+
+// DensityGrid estimates the density of Points (per square kilometer) at the centers of the cells of a grid over a
+// bounding box, using a kernel density estimate with great-circle distances. If minLon is greater than maxLon,
+// the bounding box is considered to cross the date line. The grid is indexed by row and column, where the first
+// row is at the south edge of the bounding box and the first column is at the west edge.
+// DensityGrid returns nil if cols or rows is not positive.
+func (idx *KDTree) DensityGrid(minLon, minLat, maxLon, maxLat float64, cols, rows int, opts DensityOptions) [][]float64 {
+	if cols <= 0 || rows <= 0 {
+		return nil
+	}
+
+	idx.RLock()
+	defer idx.RUnlock()
+
+	width := maxLon - minLon
+	if minLon > maxLon {
+		width += 360
+	}
+	lonStep := width / float64(cols)
+	latStep := (maxLat - minLat) / float64(rows)
+
+	grid := make([][]float64, rows)
+	for row := range grid {
+		grid[row] = make([]float64, cols)
+		lat := minLat + (float64(row)+0.5)*latStep
+		for col := range grid[row] {
+			lon := wrapLon(minLon + (float64(col)+0.5)*lonStep)
+			grid[row][col] = idx.density(Coordinates{lon: lon, lat: lat}, opts)
+		}
+	}
+	return grid
+}
+
+// density estimates the density of points at a location. The caller is responsible for locking.
+func (idx *KDTree) density(at Point, opts DensityOptions) float64 {
+	h := opts.BandwidthKm
+	if h <= 0 {
+		return 0
+	}
+	support := h
+	if opts.Kernel == GaussianKernel {
+		support = gaussianSupport * h
+	}
+
+	cosLat := math.Cos(at.Lat() * rad)
+	sum := 0.0
+	idx.within(at, kmToHaverSin(support), func(i int) {
+		u := haverSinToKm(haverSinDist(at, idx.coords[2*i], idx.coords[2*i+1], cosLat)) / h
+		sum += opts.Kernel.weight(u)
+	})
+	return sum / (h * h)
+}
+
+// weight gets the two-dimensional kernel weight for a distance measured in bandwidths
+func (k Kernel) weight(u float64) float64 {
+	switch k {
+	case EpanechnikovKernel:
+		if u >= 1 {
+			return 0
+		}
+		return 2 / math.Pi * (1 - u*u)
+	default:
+		return math.Exp(-u*u/2) / (2 * math.Pi)
+	}
+}
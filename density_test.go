@@ -0,0 +1,61 @@
+package neighborhood
+
+import (
+	"math"
+	"testing"
+)
+
+func TestKDTree_Density(t *testing.T) {
+	idx := NewIndex().Load(namedPoints()...).(*KDTree)
+
+	// a single point right at the location contributes the kernel's peak weight
+	opts := DensityOptions{Kernel: EpanechnikovKernel, BandwidthKm: 10}
+	density := idx.Density(points["memphis"], opts)
+	assertEqual(t, true, math.Abs(density-2/math.Pi/100) < 1e-9)
+
+	// seattle and woodinville are ~24 km apart, so only the gaussian kernel reaches both
+	density = idx.Density(points["seattle"], opts)
+	assertEqual(t, true, math.Abs(density-2/math.Pi/100) < 1e-9)
+	opts.Kernel = GaussianKernel
+	assertEqual(t, true, idx.Density(points["seattle"], opts) > 1/(2*math.Pi)/100)
+
+	// nothing nearby
+	assertEqual(t, 0.0, idx.Density(NewCoordinates(0, -60), opts))
+	assertEqual(t, 0.0, idx.Density(points["seattle"], DensityOptions{Kernel: GaussianKernel}))
+}
+
+func TestKDTree_Density_Integral(t *testing.T) {
+	idx := NewIndex().Load(NewCoordinates(0, 0)).(*KDTree)
+
+	for _, kernel := range []Kernel{GaussianKernel, EpanechnikovKernel} {
+		opts := DensityOptions{Kernel: kernel, BandwidthKm: 50}
+
+		// the density of a single point integrates to roughly one over the area around it
+		grid := idx.DensityGrid(-3, -3, 3, 3, 60, 60, opts)
+		cellKm := 0.1 * 2 * math.Pi * earthRadiusKm / 360
+		total := 0.0
+		for _, row := range grid {
+			for _, density := range row {
+				total += density * cellKm * cellKm
+			}
+		}
+		assertEqual(t, true, math.Abs(total-1) < 0.02)
+	}
+}
+
+func TestKDTree_DensityGrid(t *testing.T) {
+	idx := NewIndex().Load(NewCoordinates(179.95, 0.5)).(*KDTree)
+	opts := DensityOptions{Kernel: EpanechnikovKernel, BandwidthKm: 50}
+
+	// a grid across the date line, with the point in the south west cell
+	grid := idx.DensityGrid(179.9, 0, -179.9, 2, 2, 2, opts)
+	assertEqual(t, 2, len(grid))
+	assertEqual(t, 2, len(grid[0]))
+	assertEqual(t, true, grid[0][0] > grid[0][1])
+	assertEqual(t, true, grid[0][0] > grid[1][0])
+	assertEqual(t, true, grid[0][1] > 0)
+
+	// grids without cells
+	assertEqual(t, true, idx.DensityGrid(179.9, 0, -179.9, 2, 0, 2, opts) == nil)
+	assertEqual(t, true, idx.DensityGrid(179.9, 0, -179.9, 2, 2, -1, opts) == nil)
+}
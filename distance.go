@@ -4,13 +4,16 @@ import "math"
 
 const rad = math.Pi / 180.0
 
+// earthRadiusKm is the mean radius of the Earth
+const earthRadiusKm = 6371.0
+
 func haverSinDist(pt1 Point, lon2, lat2, cosLat1 float64) float64 {
 	haverSinDLon := haverSin((pt1.Lon() - lon2) * rad)
 	return haverSinDistPartial(haverSinDLon, cosLat1, pt1.Lat(), lat2)
 }
 
 // boxDist gets the lower bound for distance from a location to points inside a bounding box
-func boxDist(pt Point, cosLat float64, node *kdTreeNode) float64 {
+func boxDist(pt Point, cosLat float64, node bounds) float64 {
 	// query point is between minimum and maximum longitudes
 	if pt.Lon() >= node.MinLon && pt.Lon() <= node.MaxLon {
 		if pt.Lat() < node.MinLat {
@@ -38,6 +41,37 @@ func boxDist(pt Point, cosLat float64, node *kdTreeNode) float64 {
 	)
 }
 
+// boxMaxDist gets the upper bound for distance from a location to points inside a bounding box.
+// The farthest point from a location is the closest point to its antipode, which is the farthest possible location.
+func boxMaxDist(pt Point, cosLat float64, node bounds) float64 {
+	antipode := Coordinates{lon: wrapLon(pt.Lon() + 180), lat: -pt.Lat()}
+	// latitudes of a location and its antipode have the same cosine
+	return 1 - boxDist(antipode, cosLat, node)
+}
+
+// boxBoxDist gets the lower bound for distance between points inside two bounding boxes
+func boxBoxDist(a, b bounds) float64 {
+	// the latitude gap between the boxes
+	dLat := math.Max(0, math.Max(a.MinLat-b.MaxLat, b.MinLat-a.MaxLat))
+
+	// the longitude gap between the boxes, going either way around the globe
+	dLon := 0.0
+	if a.MaxLon < b.MinLon || b.MaxLon < a.MinLon {
+		west, east := a, b
+		if b.MaxLon < a.MinLon {
+			west, east = b, a
+		}
+		dLon = math.Min(east.MinLon-west.MaxLon, west.MinLon+360-east.MaxLon)
+	}
+
+	// cosine of latitude is smallest at the latitude farthest from the equator
+	cosA := math.Min(math.Cos(a.MinLat*rad), math.Cos(a.MaxLat*rad))
+	cosB := math.Min(math.Cos(b.MinLat*rad), math.Cos(b.MaxLat*rad))
+
+	// every term of the haversine formula is at its minimum, so the sum is a lower bound
+	return haverSin(dLat*rad) + cosA*cosB*haverSin(dLon*rad)
+}
+
 func haverSin(theta float64) float64 {
 	s := math.Sin(theta / 2)
 	return math.Pow(s, 2)
@@ -57,3 +91,26 @@ func vertexLat(lat, haverSinDLon float64) float64 {
 	}
 	return math.Atan(math.Tan(lat*rad)/cosDLon) / rad
 }
+
+// kmToHaverSin converts a great-circle distance in kilometers to the haversine of its central angle,
+// which is the distance measure used throughout the index
+func kmToHaverSin(km float64) float64 {
+	theta := km / earthRadiusKm
+	if theta < 0 {
+		return -1 // nothing is closer than zero
+	}
+	if theta >= math.Pi {
+		return 1
+	}
+	return haverSin(theta)
+}
+
+// haverSinToKm converts the haversine of a central angle back to a great-circle distance in kilometers
+func haverSinToKm(h float64) float64 {
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(math.Min(math.Max(h, 0), 1)))
+}
+
+// wrapLon wraps a longitude into the range [-180, 180)
+func wrapLon(lon float64) float64 {
+	return math.Mod(math.Mod(lon+180, 360)+360, 360) - 180
+}
@@ -34,3 +34,16 @@ func distanceKm(pt1, pt2 Point) float64 {
 	var h = haverSinDist(pt1, pt2.Lon(), pt2.Lat(), math.Cos(pt1.Lat()*rad))
 	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
 }
+
+func TestBoxMaxDist(t *testing.T) {
+	box := bounds{MinLon: -10, MinLat: -10, MaxLon: 10, MaxLat: 10}
+	origin := NewCoordinates(0, 0)
+
+	// the farthest corner of the box
+	corner := haverSinDist(origin, 10, 10, 1)
+	assertEqual(t, true, math.Abs(boxMaxDist(origin, 1, box)-corner) < 1e-12)
+
+	// a box that contains the antipode includes the farthest possible location
+	box = bounds{MinLon: 170, MinLat: -10, MaxLon: 180, MaxLat: 10}
+	assertEqual(t, true, math.Abs(boxMaxDist(origin, 1, box)-1) < 1e-12)
+}
@@ -0,0 +1,59 @@
+package neighborhood
+
+import "math"
+
+// DiversityOptions defines constraints that spread out the results of NearbyDiverse
+type DiversityOptions struct {
+	// MinSeparationKm is the minimum distance in kilometers between any two results
+	MinSeparationKm float64
+
+	// Key optionally groups Points (by data center, for example), so that at most MaxPerKey results are in a group.
+	// A MaxPerKey that is not positive does not limit the groups.
+	Key       func(p Point) string
+	MaxPerKey int
+}
+
+// NearbyDiverse finds k nearby Points that meet the Accepter criteria and are spread out according to the
+// DiversityOptions. Candidates are considered in the same order as Nearby, and a candidate is selected if it is at
+// least MinSeparationKm from every selected Point and its group has less than MaxPerKey selected Points.
+// NearbyDiverse may return less than k results if it cannot find k Points in the Index that meet the criteria.
+func (idx *KDTree) NearbyDiverse(origin Point, k int, accept Accepter, opts DiversityOptions) []Point {
+	idx.RLock()
+	defer idx.RUnlock()
+
+	result := make([]Point, 0, k)
+	perKey := make(map[string]int)
+	minDist := kmToHaverSin(opts.MinSeparationKm)
+
+	// diverse checks a candidate against the Points that are already selected
+	diverse := func(pt Point) bool {
+		if opts.Key != nil && opts.MaxPerKey > 0 && perKey[opts.Key(pt)] >= opts.MaxPerKey {
+			return false
+		}
+		if opts.MinSeparationKm > 0 {
+			cosLat := math.Cos(pt.Lat() * rad)
+			for _, selected := range result {
+				if haverSinDist(pt, selected.Lon(), selected.Lat(), cosLat) < minDist {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	search := idx.nearbySearch(origin, accept)
+	for len(result) < k {
+		itm := search.next()
+		if itm == nil {
+			break
+		}
+		if !diverse(itm.point) {
+			continue
+		}
+		result = append(result, itm.point)
+		if opts.Key != nil && opts.MaxPerKey > 0 {
+			perKey[opts.Key(itm.point)]++
+		}
+	}
+	return result
+}
@@ -0,0 +1,74 @@
+package neighborhood
+
+import (
+	"fmt"
+	"testing"
+)
+
+type DataCenterPoint struct {
+	Point
+	Name       string
+	DataCenter string
+}
+
+func dataCenterPoints() []Point {
+	var pts []Point
+	// three servers in each of two seattle data centers, and one in woodinville
+	for i := 0; i < 3; i++ {
+		pts = append(pts,
+			&DataCenterPoint{Point: NewCoordinates(-122.40+0.0001*float64(i), 47.60), Name: fmt.Sprint("sea-a-", i), DataCenter: "sea-a"},
+			&DataCenterPoint{Point: NewCoordinates(-122.30+0.0001*float64(i), 47.60), Name: fmt.Sprint("sea-b-", i), DataCenter: "sea-b"},
+		)
+	}
+	return append(pts, &DataCenterPoint{Point: points["woodinville"], Name: "woodinville", DataCenter: "woodinville"})
+}
+
+func TestKDTree_NearbyDiverse_MinSeparation(t *testing.T) {
+	idx := NewIndex().Load(dataCenterPoints()...).(*KDTree)
+	origin := NewCoordinates(-122.4, 47.6)
+
+	// without constraints, it's the same as Nearby
+	results := idx.NearbyDiverse(origin, 3, AcceptAny, DiversityOptions{})
+	expected := idx.Nearby(origin, 3, AcceptAny)
+	for i := range expected {
+		assertEqual(t, expected[i], results[i])
+	}
+
+	// one server per location
+	results = idx.NearbyDiverse(origin, 5, AcceptAny, DiversityOptions{MinSeparationKm: 1})
+	assertEqual(t, 3, len(results))
+	assertEqual(t, "sea-a-0", results[0].(*DataCenterPoint).Name)
+	assertEqual(t, "sea-b-0", results[1].(*DataCenterPoint).Name)
+	assertEqual(t, "woodinville", results[2].(*DataCenterPoint).Name)
+}
+
+func TestKDTree_NearbyDiverse_MaxPerKey(t *testing.T) {
+	idx := NewIndex().Load(dataCenterPoints()...).(*KDTree)
+	origin := NewCoordinates(-122.4, 47.6)
+
+	opts := DiversityOptions{
+		Key:       func(p Point) string { return p.(*DataCenterPoint).DataCenter },
+		MaxPerKey: 2,
+	}
+	results := idx.NearbyDiverse(origin, 5, AcceptAny, opts)
+	assertEqual(t, 5, len(results))
+	assertEqual(t, "sea-a-0", results[0].(*DataCenterPoint).Name)
+	assertEqual(t, "sea-a-1", results[1].(*DataCenterPoint).Name)
+	assertEqual(t, "sea-b-0", results[2].(*DataCenterPoint).Name)
+	assertEqual(t, "sea-b-1", results[3].(*DataCenterPoint).Name)
+	assertEqual(t, "woodinville", results[4].(*DataCenterPoint).Name)
+
+	results = idx.NearbyDiverse(origin, 5, func(p Point) bool {
+		return p.(*DataCenterPoint).DataCenter != "sea-b"
+	}, opts)
+	assertEqual(t, 3, len(results))
+
+	// a MaxPerKey that is not positive does not limit the groups
+	opts.MaxPerKey = 0
+	results = idx.NearbyDiverse(origin, 5, AcceptAny, opts)
+	expected := idx.Nearby(origin, 5, AcceptAny)
+	assertEqual(t, len(expected), len(results))
+	for i := range expected {
+		assertEqual(t, expected[i], results[i])
+	}
+}
@@ -0,0 +1,153 @@
+package neighborhood
+
+import (
+	"math"
+	"sync"
+)
+
+// ECEFTree implements the Index interface with a flat kd-tree of Earth-centered 3D unit vectors that splits on the
+// x, y and z axes in turn. Unlike the longitude and latitude boxes of the KDTree, its node boxes do not get skewed
+// near the poles and never wrap around the date line, and the chord distance to a box is an exact lower bound.
+type ECEFTree struct {
+	sync.RWMutex
+	nodeSize int
+	points   []Point
+	ids      []int
+	coords   []float64 // x, y and z of each unit vector
+}
+
+// ECEFTreeOptions defines configurable options for the ECEFTree index
+type ECEFTreeOptions struct {
+	NodeSize int
+}
+
+// DefaultECEFTreeOptions gets the default ECEFTree options, which you can use directly or modify before creating an
+// Index
+func DefaultECEFTreeOptions() ECEFTreeOptions {
+	return ECEFTreeOptions{
+		NodeSize: 64,
+	}
+}
+
+// NewECEFTreeIndex creates a new ECEFTree Index implementation with given ECEFTreeOptions
+func NewECEFTreeIndex(opts ECEFTreeOptions) Index {
+	return &ECEFTree{
+		nodeSize: opts.NodeSize,
+	}
+}
+
+// Load adds searchable Points to the Index.
+// Each call to Load will replace all Points in the Index with the provided Points.
+// Load mutates and returns the Index to allow call chaining.
+func (idx *ECEFTree) Load(points ...Point) Index {
+	idx.Lock()
+	defer idx.Unlock()
+
+	idx.ids = make([]int, len(points))
+	idx.coords = make([]float64, 3*len(points))
+	for i, pt := range points {
+		v := toVec3(pt.Lon(), pt.Lat())
+		idx.ids[i] = i
+		idx.coords[3*i] = v.x
+		idx.coords[3*i+1] = v.y
+		idx.coords[3*i+2] = v.z
+	}
+	idx.points = points
+
+	// kd-sort both arrays for efficient search (see comments in kd-sort.go)
+	kdSort(idx.ids, idx.coords, 3, idx.nodeSize, 0, len(idx.ids)-1, 0)
+	return idx
+}
+
+// Add will update the index with the provided points, while persisting the existing points.
+// Add returns the Index after it is complete to allow call chaining. Add is as-expensive as Load
+func (idx *ECEFTree) Add(points ...Point) Index {
+	return idx.Load(append(idx.points, points...)...)
+}
+
+// Nearby finds the k nearest Points to the origin that meet the Accepter criteria.
+// If there are multiple Points that are the same distance from the origin and the Points implement the Ranker
+// interface, the higher ranking Points will be preferred. Points with the same distance and rank are returned in the
+// order they were loaded (by Load, followed by Add). Nearby may return less than k results if it cannot find k
+// Points in the Index that meet the Accepter criteria.
+func (idx *ECEFTree) Nearby(origin Point, k int, accept Accepter) []Point {
+	idx.RLock()
+	defer idx.RUnlock()
+
+	result := make([]Point, 0, k)
+	v := toVec3(origin.Lon(), origin.Lat())
+
+	// the squared chord between unit vectors is four times the haversine of their central angle,
+	// so distances are comparable to (and tie the same way as) the KDTree's
+	pushPoint := func(q *priorityQueue, i int) {
+		if pt := idx.points[idx.ids[i]]; accept(pt) {
+			dx, dy, dz := idx.coords[3*i]-v.x, idx.coords[3*i+1]-v.y, idx.coords[3*i+2]-v.z
+			q.PushPoint(pt, (dx*dx+dy*dy+dz*dz)/4, idx.ids[i])
+		}
+	}
+
+	q := newPriorityQueue(idx.nodeSize)
+	q.PushNodeDist(&ecefNode{
+		Left:  0,
+		Right: len(idx.ids) - 1,
+		Min:   [3]float64{-1, -1, -1},
+		Max:   [3]float64{1, 1, 1},
+	}, 0)
+
+	for len(result) < k {
+		itm := q.PopItem()
+		if itm == nil {
+			break
+		}
+		if itm.point != nil {
+			result = append(result, itm.point)
+			continue
+		}
+
+		node := itm.node.(*ecefNode)
+		if node.Right-node.Left <= idx.nodeSize { // leaf node
+			for i := node.Left; i <= node.Right; i++ {
+				pushPoint(&q, i)
+			}
+			continue
+		}
+
+		// not a leaf node, so add the middle point and both halves to the queue
+		m := (node.Left + node.Right) >> 1
+		pushPoint(&q, m)
+		split := idx.coords[3*m+node.Axis]
+		leftNode := &ecefNode{Left: node.Left, Right: m - 1, Axis: (node.Axis + 1) % 3, Min: node.Min, Max: node.Max}
+		leftNode.Max[node.Axis] = split
+		rightNode := &ecefNode{Left: m + 1, Right: node.Right, Axis: (node.Axis + 1) % 3, Min: node.Min, Max: node.Max}
+		rightNode.Min[node.Axis] = split
+
+		for _, child := range []*ecefNode{leftNode, rightNode} {
+			if child.Right >= child.Left {
+				q.PushNodeDist(child, child.dist(v)/4)
+			}
+		}
+	}
+	return result
+}
+
+// ecefNode defines a 3D box of points in the ECEFTree
+type ecefNode struct {
+	Left  int // left index in the kd-tree array
+	Right int // right index
+	Axis  int // 0 for x axis, 1 for y axis and 2 for z axis
+
+	// bounding box of the node
+	Min [3]float64
+	Max [3]float64
+}
+
+// dist gets the squared distance from a vector to the closest point of the box, which is a lower bound for the
+// squared chord to the points inside it
+func (node *ecefNode) dist(v vec3) float64 {
+	d := 0.0
+	for axis, c := range [3]float64{v.x, v.y, v.z} {
+		gap := math.Max(0, math.Max(node.Min[axis]-c, c-node.Max[axis]))
+		d += gap * gap
+	}
+	return d
+}
@@ -0,0 +1,70 @@
+package neighborhood
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestECEFTree_Nearby(t *testing.T) {
+	idx := NewECEFTreeIndex(ECEFTreeOptions{NodeSize: 2}).Load(namedPoints()...)
+
+	results := idx.Nearby(NewCoordinates(-115, 45), 3, AcceptAny)
+	assertEqual(t, 3, len(results))
+	assertEqual(t, "woodinville", results[0].(*NamedPoint).Name)
+	assertEqual(t, "seattle", results[1].(*NamedPoint).Name)
+	assertEqual(t, "memphis", results[2].(*NamedPoint).Name)
+
+	results = idx.Nearby(NewCoordinates(-115, 45), 3, func(pt Point) bool {
+		return pt.(*NamedPoint).Name != "memphis"
+	})
+	assertEqual(t, "anchorage", results[2].(*NamedPoint).Name)
+
+	// across the date line
+	results = idx.Nearby(NewCoordinates(-175, 60), 3, AcceptAny)
+	assertEqual(t, "eastrussia", results[0].(*NamedPoint).Name)
+	assertEqual(t, "anchorage", results[1].(*NamedPoint).Name)
+	assertEqual(t, "seattle", results[2].(*NamedPoint).Name)
+
+	assertEqual(t, 8, len(idx.Nearby(NewCoordinates(-175, 60), 10, AcceptAny)))
+	assertEqual(t, 0, len(NewECEFTreeIndex(DefaultECEFTreeOptions()).Nearby(NewCoordinates(0, 0), 10, AcceptAny)))
+}
+
+func TestECEFTree_Nearby_Ranked(t *testing.T) {
+	pts := []Point{
+		&RankedPoint{Point: points["seattle"], Name: "seattle-less-important", Rank: 1},
+		&RankedPoint{Point: points["seattle"], Name: "seattle-more-important", Rank: 5},
+		&RankedPoint{Point: points["woodinville"], Name: "woodinville-super-important", Rank: 5000},
+	}
+	idx := NewECEFTreeIndex(DefaultECEFTreeOptions()).Load(pts[:1]...)
+	idx.Add(pts[1:]...)
+
+	results := idx.Nearby(NewCoordinates(-122, 47), 3, AcceptAny)
+	assertEqual(t, 3, len(results))
+	assertEqual(t, "seattle-more-important", results[0].(*RankedPoint).Name)
+	assertEqual(t, "seattle-less-important", results[1].(*RankedPoint).Name)
+	assertEqual(t, "woodinville-super-important", results[2].(*RankedPoint).Name)
+}
+
+func TestECEFTree_Nearby_MatchesKDTree(t *testing.T) {
+	rnd := rand.New(rand.NewSource(13))
+	pts := make([]Point, 5_000)
+	for i := range pts {
+		pts[i] = NewCoordinates(rnd.Float64()*360-180, rnd.Float64()*180-90)
+	}
+	ecef := NewECEFTreeIndex(ECEFTreeOptions{NodeSize: 8}).Load(pts...)
+	kd := NewKDTreeIndex(KDTreeOptions{NodeSize: 8}).Load(pts...)
+
+	// include origins near the poles and the date line
+	origins := []Point{NewCoordinates(0, 90), NewCoordinates(180, 0), NewCoordinates(-179.9, -89.9)}
+	for trial := 0; trial < 10; trial++ {
+		origins = append(origins, NewCoordinates(rnd.Float64()*360-180, rnd.Float64()*180-90))
+	}
+	for _, origin := range origins {
+		expected := kd.Nearby(origin, 20, AcceptAny)
+		results := ecef.Nearby(origin, 20, AcceptAny)
+		assertEqual(t, len(expected), len(results))
+		for i := range expected {
+			assertEqual(t, int(distanceKm(origin, expected[i])*1000), int(distanceKm(origin, results[i])*1000))
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package neighborhood
+
+import (
+	"sync"
+	"time"
+)
+
+// Expirer is an optional interface to define when a Point expires
+type Expirer interface {
+	// ExpiresAt gets the time at which the Point is no longer valid
+	ExpiresAt() time.Time
+}
+
+// ExpiringIndex implements the Index interface by wrapping another Index and hiding expired Points (see Expirer)
+// from searches as soon as they expire. Expired Points are compacted out of the wrapped Index in the background
+// by reloading it with the remaining Points. Points that do not implement Expirer never expire.
+type ExpiringIndex struct {
+	mu     sync.Mutex // serializes changes to the Points
+	idx    Index
+	points []Point
+	now    func() time.Time
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewExpiringIndex creates a new ExpiringIndex that wraps an Index and compacts it every compactInterval.
+// If compactInterval is not positive, expired Points are only compacted by calling Compact.
+// Call Close to stop compacting in the background.
+func NewExpiringIndex(idx Index, compactInterval time.Duration) *ExpiringIndex {
+	ei := &ExpiringIndex{
+		idx:  idx,
+		now:  time.Now,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	if compactInterval > 0 {
+		go ei.compactEvery(compactInterval)
+	} else {
+		close(ei.done)
+	}
+	return ei
+}
+
+// Nearby finds the k nearest unexpired Points to the origin that meet the Accepter criteria.
+// See Index for details.
+func (ei *ExpiringIndex) Nearby(origin Point, k int, accept Accepter) []Point {
+	now := ei.now()
+	return ei.idx.Nearby(origin, k, func(p Point) bool {
+		return !expired(p, now) && accept(p)
+	})
+}
+
+// Load will replace all Points in the Index with the provided Points.
+// Load mutates and returns the Index to allow call chaining.
+func (ei *ExpiringIndex) Load(points ...Point) Index {
+	ei.mu.Lock()
+	defer ei.mu.Unlock()
+
+	ei.points = points
+	ei.idx.Load(points...)
+	return ei
+}
+
+// Add will update the index with the provided points, while persisting the existing points.
+// Add returns the Index after it is complete to allow call chaining. Add is as-expensive as Load
+func (ei *ExpiringIndex) Add(points ...Point) Index {
+	ei.mu.Lock()
+	defer ei.mu.Unlock()
+
+	ei.points = append(ei.points, points...)
+	ei.idx.Load(ei.points...)
+	return ei
+}
+
+// Compact reloads the wrapped Index without expired Points, if there are any.
+// Compact returns the number of Points that were removed.
+func (ei *ExpiringIndex) Compact() int {
+	ei.mu.Lock()
+	defer ei.mu.Unlock()
+
+	now := ei.now()
+	remaining := make([]Point, 0, len(ei.points))
+	for _, pt := range ei.points {
+		if !expired(pt, now) {
+			remaining = append(remaining, pt)
+		}
+	}
+	removed := len(ei.points) - len(remaining)
+	if removed > 0 {
+		ei.points = remaining
+		ei.idx.Load(remaining...)
+	}
+	return removed
+}
+
+// Close stops compacting in the background
+func (ei *ExpiringIndex) Close() {
+	ei.closeOnce.Do(func() { close(ei.stop) })
+	<-ei.done
+}
+
+// compactEvery compacts the Index at an interval until the ExpiringIndex is closed
+func (ei *ExpiringIndex) compactEvery(interval time.Duration) {
+	defer close(ei.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ei.Compact()
+		case <-ei.stop:
+			return
+		}
+	}
+}
+
+// expired reports whether a Point implements Expirer and is expired at the given time
+func expired(p Point, now time.Time) bool {
+	if e, ok := p.(Expirer); ok {
+		return !now.Before(e.ExpiresAt())
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package neighborhood
+
+import (
+	"testing"
+	"time"
+)
+
+type ExpiringPoint struct {
+	Point
+	Name    string
+	Expires time.Time
+}
+
+func (p *ExpiringPoint) ExpiresAt() time.Time {
+	return p.Expires
+}
+
+func TestExpiringIndex_Nearby(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	ei := NewExpiringIndex(NewIndex(), 0)
+	defer ei.Close()
+	ei.now = func() time.Time { return start }
+
+	ei.Load(
+		&ExpiringPoint{Point: points["seattle"], Name: "seattle", Expires: start.Add(time.Minute)},
+		&ExpiringPoint{Point: points["woodinville"], Name: "woodinville", Expires: start.Add(time.Hour)},
+		namedPoint("memphis"), // never expires
+	)
+	origin := NewCoordinates(-122, 47)
+
+	results := ei.Nearby(origin, 2, AcceptAny)
+	assertEqual(t, 2, len(results))
+	assertEqual(t, "seattle", results[0].(*ExpiringPoint).Name)
+	assertEqual(t, "woodinville", results[1].(*ExpiringPoint).Name)
+
+	// seattle is hidden as soon as it expires, before compaction
+	ei.now = func() time.Time { return start.Add(time.Minute) }
+	results = ei.Nearby(origin, 2, AcceptAny)
+	assertEqual(t, 2, len(results))
+	assertEqual(t, "woodinville", results[0].(*ExpiringPoint).Name)
+	assertEqual(t, "memphis", results[1].(*NamedPoint).Name)
+
+	// the Accepter still applies
+	results = ei.Nearby(origin, 2, func(p Point) bool {
+		_, ok := p.(*NamedPoint)
+		return ok
+	})
+	assertEqual(t, 1, len(results))
+
+	ei.now = func() time.Time { return start.Add(24 * time.Hour) }
+	results = ei.Nearby(origin, 2, AcceptAny)
+	assertEqual(t, 1, len(results))
+	assertEqual(t, "memphis", results[0].(*NamedPoint).Name)
+}
+
+func TestExpiringIndex_Compact(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	idx := NewIndex()
+	ei := NewExpiringIndex(idx, 0)
+	defer ei.Close()
+	ei.now = func() time.Time { return start }
+
+	ei.Load(&ExpiringPoint{Point: points["seattle"], Name: "seattle", Expires: start.Add(time.Minute)})
+	ei.Add(&ExpiringPoint{Point: points["woodinville"], Name: "woodinville", Expires: start.Add(time.Hour)})
+	origin := NewCoordinates(-122, 47)
+	assertEqual(t, 0, ei.Compact())
+	assertEqual(t, 2, len(idx.Nearby(origin, 5, AcceptAny)))
+
+	ei.now = func() time.Time { return start.Add(time.Minute) }
+	assertEqual(t, 1, ei.Compact())
+	// the wrapped index no longer has the expired point
+	results := idx.Nearby(origin, 5, AcceptAny)
+	assertEqual(t, 1, len(results))
+	assertEqual(t, "woodinville", results[0].(*ExpiringPoint).Name)
+}
+
+func TestExpiringIndex_Background(t *testing.T) {
+	idx := NewIndex()
+	ei := NewExpiringIndex(idx, time.Millisecond)
+	ei.Load(
+		&ExpiringPoint{Point: points["seattle"], Name: "seattle", Expires: time.Now()},
+		namedPoint("memphis"),
+	)
+	origin := NewCoordinates(-122, 47)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(idx.Nearby(origin, 5, AcceptAny)) > 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("expired point was never compacted")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	ei.Close()
+	ei.Close() // closing twice is fine
+}
@@ -0,0 +1,81 @@
+//go:build go1.18
+// +build go1.18
+
+package neighborhood
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// Run a fuzz target with go test -fuzz=FuzzNearby (see property_test.go for the properties)
+
+func FuzzNearby(f *testing.F) {
+	f.Add(int64(1), uint16(100), 0.0, 90.0, uint8(5))
+	f.Add(int64(2), uint16(1000), 180.0, 0.0, uint8(50))
+	f.Add(int64(3), uint16(10), -122.4, 47.6, uint8(0))
+	f.Fuzz(func(t *testing.T, seed int64, n uint16, lon, lat float64, k uint8) {
+		origin, ok := fuzzLocation(lon, lat)
+		if !ok {
+			t.Skip()
+		}
+		rnd := rand.New(rand.NewSource(seed))
+		pts := randomTestPoints(rnd, int(n%2_000))
+		accept := randomTestAccepter(rnd)
+		checkProperty(t, seed, pts, nearbyProperty(origin, int(k), accept))
+		checkProperty(t, seed, pts, farthestProperty(origin, int(k), accept))
+		checkProperty(t, seed, pts, indexesProperty(origin, int(k), accept))
+	})
+}
+
+func FuzzWithin(f *testing.F) {
+	f.Add(int64(1), uint16(100), 0.0, 90.0, 1000.0)
+	f.Add(int64(2), uint16(1000), 180.0, 0.0, 20_000.0)
+	f.Add(int64(3), uint16(10), -122.4, 47.6, 0.0)
+	f.Fuzz(func(t *testing.T, seed int64, n uint16, lon, lat, radiusKm float64) {
+		origin, ok := fuzzLocation(lon, lat)
+		if !ok || math.IsNaN(radiusKm) {
+			t.Skip()
+		}
+		rnd := rand.New(rand.NewSource(seed))
+		pts := randomTestPoints(rnd, int(n%2_000))
+		accept := randomTestAccepter(rnd)
+		checkProperty(t, seed, pts, withinProperty(origin, radiusKm, accept))
+		checkProperty(t, seed, pts, corridorProperty([]Point{origin, randomTestPoint(rnd, pts)}, radiusKm, accept))
+	})
+}
+
+func FuzzRange(f *testing.F) {
+	f.Add(int64(1), uint16(100), -10.0, -10.0, 10.0, 10.0)
+	f.Add(int64(2), uint16(1000), 170.0, 80.0, -170.0, 90.0)
+	f.Fuzz(func(t *testing.T, seed int64, n uint16, minLon, minLat, maxLon, maxLat float64) {
+		for _, v := range []float64{minLon, minLat, maxLon, maxLat} {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				t.Skip()
+			}
+		}
+		rnd := rand.New(rand.NewSource(seed))
+		pts := randomTestPoints(rnd, int(n%2_000))
+		b := bounds{MinLon: minLon, MinLat: minLat, MaxLon: maxLon, MaxLat: maxLat}
+		checkProperty(t, seed, pts, rangeProperty(b, randomTestAccepter(rnd)))
+	})
+}
+
+func FuzzClosestPairs(f *testing.F) {
+	f.Add(int64(1), uint16(100), uint8(5))
+	f.Add(int64(2), uint16(300), uint8(100))
+	f.Fuzz(func(t *testing.T, seed int64, n uint16, k uint8) {
+		rnd := rand.New(rand.NewSource(seed))
+		pts := randomTestPoints(rnd, int(n%300))
+		checkProperty(t, seed, pts, closestPairsProperty(int(k), randomTestAccepter(rnd)))
+	})
+}
+
+// fuzzLocation converts fuzzed numbers to a valid location
+func fuzzLocation(lon, lat float64) (Point, bool) {
+	if math.IsNaN(lon) || math.IsInf(lon, 0) || math.IsNaN(lat) || math.IsInf(lat, 0) {
+		return nil, false
+	}
+	return NewCoordinates(wrapLon(lon), math.Max(-90, math.Min(90, lat))), true
+}
@@ -0,0 +1,338 @@
+package neighborhood
+
+import (
+	"math"
+	"reflect"
+	"sync"
+)
+
+// geohashAlphabet is the base32 alphabet of geohash strings
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// maxGeohashPrecision is the longest geohash that fits the 64 bit cell keys
+const maxGeohashPrecision = 12
+
+// GeohashGrid implements the Index interface with a hash grid of geohash cells. Unlike the KDTree, Points can be
+// added and removed in constant time without rebuilding the Index, which suits datasets that change often.
+// Nearby searches are not ring expansions over neighboring cells: they are best-first searches over the geohash
+// prefixes of the cells, closest bounding box first, and skip empty areas by counting the Points under every prefix.
+type GeohashGrid struct {
+	sync.RWMutex
+	precision int
+	cells     map[uint64]*gridCell // cells by geohash bits
+	prefixes  []map[uint64]int     // number of Points by geohash prefix bits, for each prefix length below precision
+	size      int                  // number of Points
+	seq       int                  // insertion order of the next Point
+}
+
+// GeohashGridOptions defines configurable options for the GeohashGrid index
+type GeohashGridOptions struct {
+	// Precision is the geohash length (between 1 and 12) of the grid cells. Smaller cells hold fewer Points to
+	// compare with the origin, while each Add and Remove updates one count per geohash character.
+	Precision int
+}
+
+// DefaultGeohashGridOptions gets the default GeohashGrid options (cells of about 5 by 5 kilometers at the equator),
+// which you can use directly or modify before creating an Index
+func DefaultGeohashGridOptions() GeohashGridOptions {
+	return GeohashGridOptions{
+		Precision: 5,
+	}
+}
+
+// NewGeohashGridIndex creates a new GeohashGrid Index implementation with given GeohashGridOptions
+func NewGeohashGridIndex(opts GeohashGridOptions) Index {
+	idx := &GeohashGrid{
+		precision: geohashPrecision(opts.Precision),
+	}
+	idx.reset()
+	return idx
+}
+
+// Load adds searchable Points to the Index.
+// Each call to Load will replace all Points in the Index with the provided Points.
+// Load mutates and returns the Index to allow call chaining.
+func (idx *GeohashGrid) Load(points ...Point) Index {
+	idx.Lock()
+	defer idx.Unlock()
+
+	idx.reset()
+	idx.add(points)
+	return idx
+}
+
+// Add will update the index with the provided points, while persisting the existing points.
+// Add returns the Index after it is complete to allow call chaining. Unlike with the KDTree, adding a Point takes
+// constant time.
+func (idx *GeohashGrid) Add(points ...Point) Index {
+	idx.Lock()
+	defer idx.Unlock()
+
+	idx.add(points)
+	return idx
+}
+
+// Remove removes the provided Points from the Index, and gets the number of Points that were removed.
+// Points are compared with ==, so they should be pointers or other comparable types; Points of types that are not
+// comparable (which would make == panic) are compared with reflect.DeepEqual instead. If a Point was added more
+// than once, each call removes one of its copies. Removing a Point takes constant time for a given cell size.
+func (idx *GeohashGrid) Remove(points ...Point) int {
+	idx.Lock()
+	defer idx.Unlock()
+
+	removed := 0
+	for _, pt := range points {
+		key := geohashBits(pt, idx.precision)
+		cell, ok := idx.cells[key]
+		if !ok {
+			continue
+		}
+		for i, e := range cell.entries {
+			if !samePoint(e.point, pt) {
+				continue
+			}
+			// the order of entries in a cell does not matter, since they keep their insertion order
+			last := len(cell.entries) - 1
+			cell.entries[i] = cell.entries[last]
+			cell.entries[last] = gridEntry{} // avoid memory leak
+			cell.entries = cell.entries[:last]
+			if len(cell.entries) == 0 {
+				delete(idx.cells, key)
+			}
+			for length := idx.precision - 1; length > 0; length-- {
+				key >>= 5
+				if idx.prefixes[length][key]--; idx.prefixes[length][key] == 0 {
+					delete(idx.prefixes[length], key)
+				}
+			}
+			idx.size--
+			removed++
+			break
+		}
+	}
+	return removed
+}
+
+// Len gets the number of Points in the Index
+func (idx *GeohashGrid) Len() int {
+	idx.RLock()
+	defer idx.RUnlock()
+
+	return idx.size
+}
+
+// Nearby finds the k nearest Points to the origin that meet the Accepter criteria.
+// If there are multiple Points that are the same distance from the origin and the Points implement the Ranker
+// interface, the higher ranking Points will be preferred. Points with the same distance and rank are returned in the
+// order they were added (by Load, followed by Add). Nearby may return less than k results if it cannot find k
+// Points in the Index that meet the Accepter criteria. The search visits geohash prefixes and then cells in order
+// of the distance to their bounding boxes, so it never has to guess how many rings of cells to expand.
+func (idx *GeohashGrid) Nearby(origin Point, k int, accept Accepter) []Point {
+	idx.RLock()
+	defer idx.RUnlock()
+
+	result := make([]Point, 0, k)
+	cosLat := math.Cos(origin.Lat() * rad)
+
+	// queue the occupied cells (or prefixes) below a prefix, with the distance to their bounding boxes
+	q := newPriorityQueue(k)
+	pushChildren := func(prefix uint64, length int) {
+		for c := uint64(0); c < 32; c++ {
+			key := prefix<<5 | c
+			if !idx.occupied(key, length+1) {
+				continue
+			}
+			q.PushNodeDist(&geohashNode{key: key, length: length + 1}, boxDist(origin, cosLat, geohashBounds(key, length+1)))
+		}
+	}
+	pushChildren(0, 0)
+
+	for len(result) < k {
+		itm := q.PopItem()
+		if itm == nil {
+			break
+		}
+		if itm.point != nil {
+			result = append(result, itm.point)
+			continue
+		}
+
+		node := itm.node.(*geohashNode)
+		if node.length < idx.precision {
+			pushChildren(node.key, node.length)
+			continue
+		}
+		for _, e := range idx.cells[node.key].entries {
+			if accept(e.point) {
+				q.PushPoint(e.point, haverSinDist(origin, e.point.Lon(), e.point.Lat(), cosLat), e.seq)
+			}
+		}
+	}
+	return result
+}
+
+func (idx *GeohashGrid) reset() {
+	idx.cells = make(map[uint64]*gridCell)
+	idx.prefixes = make([]map[uint64]int, idx.precision)
+	for length := 1; length < idx.precision; length++ {
+		idx.prefixes[length] = make(map[uint64]int)
+	}
+	idx.size = 0
+	idx.seq = 0
+}
+
+func (idx *GeohashGrid) add(points []Point) {
+	for _, pt := range points {
+		key := geohashBits(pt, idx.precision)
+		cell, ok := idx.cells[key]
+		if !ok {
+			cell = &gridCell{}
+			idx.cells[key] = cell
+		}
+		cell.entries = append(cell.entries, gridEntry{point: pt, seq: idx.seq})
+		for length := idx.precision - 1; length > 0; length-- {
+			key >>= 5
+			idx.prefixes[length][key]++
+		}
+		idx.seq++
+	}
+	idx.size += len(points)
+}
+
+// samePoint compares Points with ==, or with reflect.DeepEqual if their dynamic values are not comparable
+// (which would make == panic)
+func samePoint(a, b Point) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !va.IsValid() || !vb.IsValid() {
+		return a == b
+	}
+	if va.Type() != vb.Type() {
+		return false
+	}
+	if !comparableValue(va) || !comparableValue(vb) {
+		return reflect.DeepEqual(a, b)
+	}
+	return a == b
+}
+
+// comparableValue checks whether == can compare a value without panicking, including the dynamic values of its
+// interface fields
+func comparableValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Interface:
+		return v.IsNil() || comparableValue(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !comparableValue(v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if !comparableValue(v.Index(i)) {
+				return false
+			}
+		}
+		return true
+	default:
+		return v.Type().Comparable()
+	}
+}
+
+// occupied checks whether there are Points in the cell or under the prefix of a given length
+func (idx *GeohashGrid) occupied(key uint64, length int) bool {
+	if length == idx.precision {
+		_, ok := idx.cells[key]
+		return ok
+	}
+	return idx.prefixes[length][key] > 0
+}
+
+// Geohash gets the geohash of a Point with a given precision (between 1 and 12 characters).
+// Longitudes outside of [-180, 180] are wrapped.
+func Geohash(pt Point, precision int) string {
+	precision = geohashPrecision(precision)
+	key := geohashBits(pt, precision)
+	hash := make([]byte, precision)
+	for i := range hash {
+		hash[i] = geohashAlphabet[(key>>uint(5*(precision-1-i)))&31]
+	}
+	return string(hash)
+}
+
+// geohashPrecision limits a geohash length to the supported range
+func geohashPrecision(precision int) int {
+	return int(math.Min(math.Max(float64(precision), 1), maxGeohashPrecision))
+}
+
+// geohashBits gets the geohash of a Point as bits, which alternate between longitude and latitude bits,
+// starting with longitude
+func geohashBits(pt Point, length int) uint64 {
+	lonBits, latBits := geohashGridBits(length)
+	cols, rows := float64(uint64(1)<<lonBits), float64(uint64(1)<<latBits)
+	lon := wrapLon(pt.Lon())
+	if pt.Lon() == 180 {
+		lon = 180 // the top of the range, like standard geohashes, instead of wrapping to -180
+	}
+	x := uint64(math.Min(math.Max(math.Floor((lon+180)/360*cols), 0), cols-1))
+	y := uint64(math.Min(math.Max(math.Floor((pt.Lat()+90)/180*rows), 0), rows-1))
+
+	key := uint64(0)
+	for b, bits := uint(0), lonBits+latBits; b < bits; b++ {
+		key <<= 1
+		if b%2 == 0 {
+			lonBits--
+			key |= (x >> lonBits) & 1
+		} else {
+			latBits--
+			key |= (y >> latBits) & 1
+		}
+	}
+	return key
+}
+
+// geohashBounds gets the bounding box of a geohash of a given length
+func geohashBounds(key uint64, length int) bounds {
+	lonBits, latBits := geohashGridBits(length)
+	x, y := uint64(0), uint64(0)
+	for b, bits := uint(0), lonBits+latBits; b < bits; b++ {
+		bit := (key >> (bits - 1 - b)) & 1
+		if b%2 == 0 {
+			x = x<<1 | bit
+		} else {
+			y = y<<1 | bit
+		}
+	}
+	width, height := 360/float64(uint64(1)<<lonBits), 180/float64(uint64(1)<<latBits)
+	return bounds{
+		MinLon: -180 + float64(x)*width,
+		MinLat: -90 + float64(y)*height,
+		MaxLon: -180 + float64(x+1)*width,
+		MaxLat: -90 + float64(y+1)*height,
+	}
+}
+
+// geohashGridBits gets the number of longitude and latitude bits in a geohash of a given length.
+// Longitude gets the extra bit when the total is odd.
+func geohashGridBits(length int) (lonBits, latBits uint) {
+	bits := uint(5 * length)
+	return (bits + 1) / 2, bits / 2
+}
+
+// geohashNode is a geohash cell or prefix in a GeohashGrid search
+type geohashNode struct {
+	key    uint64
+	length int
+}
+
+// gridCell holds the Points inside a cell of the GeohashGrid
+type gridCell struct {
+	entries []gridEntry
+}
+
+// gridEntry is a Point in a gridCell, with its insertion order
+type gridEntry struct {
+	point Point
+	seq   int
+}
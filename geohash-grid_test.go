@@ -0,0 +1,130 @@
+package neighborhood
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestGeohash(t *testing.T) {
+	pt := NewCoordinates(10.40744, 57.64911)
+	assertEqual(t, "u4pruydqqvj", Geohash(pt, 11))
+	assertEqual(t, "u4pru", Geohash(pt, 5))
+	assertEqual(t, "u", Geohash(pt, 1))
+
+	// the corners of the range
+	assertEqual(t, "zzzzzzzzzzzz", Geohash(NewCoordinates(180, 90), 12))
+	assertEqual(t, "000000000000", Geohash(NewCoordinates(-180, -90), 12))
+	assertEqual(t, "pbpbpbpbpbpb", Geohash(NewCoordinates(180, -90), 12))
+	assertEqual(t, Geohash(NewCoordinates(-170, 10), 6), Geohash(NewCoordinates(190, 10), 6))
+	assertEqual(t, "ezs42", Geohash(NewCoordinates(-5.6, 42.6), 5))
+	assertEqual(t, "6", Geohash(points["saopaulo"], 0)) // precision is at least 1
+}
+
+func TestGeohashGrid_Nearby(t *testing.T) {
+	idx := NewGeohashGridIndex(DefaultGeohashGridOptions()).Load(namedPoints()...)
+
+	results := idx.Nearby(NewCoordinates(-115, 45), 3, AcceptAny)
+	assertEqual(t, 3, len(results))
+	assertEqual(t, "woodinville", results[0].(*NamedPoint).Name)
+	assertEqual(t, "seattle", results[1].(*NamedPoint).Name)
+	assertEqual(t, "memphis", results[2].(*NamedPoint).Name)
+
+	// across the date line
+	results = idx.Nearby(NewCoordinates(-175, 60), 3, AcceptAny)
+	assertEqual(t, "eastrussia", results[0].(*NamedPoint).Name)
+	assertEqual(t, "anchorage", results[1].(*NamedPoint).Name)
+	assertEqual(t, "seattle", results[2].(*NamedPoint).Name)
+
+	results = idx.Nearby(NewCoordinates(-115, 45), 10, func(pt Point) bool {
+		return pt.(*NamedPoint).Name != "memphis"
+	})
+	assertEqual(t, 7, len(results))
+	assertEqual(t, "anchorage", results[2].(*NamedPoint).Name)
+
+	assertEqual(t, 0, len(NewGeohashGridIndex(DefaultGeohashGridOptions()).Nearby(NewCoordinates(0, 0), 10, AcceptAny)))
+}
+
+func TestGeohashGrid_Nearby_Ranked(t *testing.T) {
+	pts := []Point{
+		&RankedPoint{Point: points["seattle"], Name: "seattle-less-important", Rank: 1},
+		&RankedPoint{Point: points["seattle"], Name: "seattle-same-importance", Rank: 1},
+		&RankedPoint{Point: points["seattle"], Name: "seattle-more-important", Rank: 5},
+	}
+	idx := NewGeohashGridIndex(DefaultGeohashGridOptions()).Load(pts[0])
+	idx.Add(pts[1:]...)
+
+	results := idx.Nearby(NewCoordinates(-122, 47), 3, AcceptAny)
+	assertEqual(t, "seattle-more-important", results[0].(*RankedPoint).Name)
+	assertEqual(t, "seattle-less-important", results[1].(*RankedPoint).Name)
+	assertEqual(t, "seattle-same-importance", results[2].(*RankedPoint).Name)
+}
+
+func TestGeohashGrid_Remove(t *testing.T) {
+	seattle, woodinville := namedPoint("seattle"), namedPoint("woodinville")
+	idx := NewGeohashGridIndex(DefaultGeohashGridOptions()).Load(seattle, woodinville, seattle).(*GeohashGrid)
+
+	assertEqual(t, 1, idx.Remove(seattle))
+	assertEqual(t, 2, len(idx.Nearby(NewCoordinates(-122, 47), 10, AcceptAny)))
+	assertEqual(t, 1, idx.Remove(seattle, seattle, namedPoint("seattle"))) // only the same Point is removed
+	results := idx.Nearby(NewCoordinates(-122, 47), 10, AcceptAny)
+	assertEqual(t, 1, len(results))
+	assertEqual(t, Point(woodinville), results[0])
+	assertEqual(t, 0, idx.Remove(seattle))
+	assertEqual(t, 1, idx.Len())
+}
+
+// taggedPoint is not comparable, because of its slice field
+type taggedPoint struct {
+	Coordinates
+	Tags []string
+}
+
+func TestGeohashGrid_Remove_NotComparable(t *testing.T) {
+	a := taggedPoint{Coordinates: Coordinates{lon: -122.3, lat: 47.6}, Tags: []string{"a"}}
+	b := taggedPoint{Coordinates: Coordinates{lon: -122.3, lat: 47.6}, Tags: []string{"b"}}
+	idx := NewGeohashGridIndex(DefaultGeohashGridOptions()).Load(a, b).(*GeohashGrid)
+
+	assertEqual(t, 1, idx.Remove(taggedPoint{Coordinates: Coordinates{lon: -122.3, lat: 47.6}, Tags: []string{"b"}}))
+	assertEqual(t, 1, idx.Len())
+	results := idx.Nearby(NewCoordinates(-122, 47), 10, AcceptAny)
+	assertEqual(t, 1, len(results))
+	assertEqual(t, "a", results[0].(taggedPoint).Tags[0])
+
+	// a comparable type that holds a Point that is not comparable
+	idx.Load(NamedPoint{Point: a, Name: "a"}, NamedPoint{Point: b, Name: "b"})
+	assertEqual(t, 1, idx.Remove(NamedPoint{Point: b, Name: "b"}))
+	assertEqual(t, 0, idx.Remove(NamedPoint{Point: b, Name: "a"}))
+	assertEqual(t, 1, idx.Len())
+}
+
+func TestGeohashGrid_Nearby_MatchesKDTree(t *testing.T) {
+	rnd := rand.New(rand.NewSource(45))
+	pts := make([]Point, 0, 3_000)
+	for i := 0; i < 2_000; i++ {
+		pts = append(pts, &NamedPoint{Point: NewCoordinates(rnd.Float64()*360-180, rnd.Float64()*180-90)})
+	}
+	// a dense cluster, to make most grid cells empty relative to it
+	for i := 0; i < 1_000; i++ {
+		pts = append(pts, &NamedPoint{Point: NewCoordinates(-122+rnd.Float64()*0.1, 47+rnd.Float64()*0.1)})
+	}
+
+	for _, precision := range []int{1, 3, 6} {
+		grid := NewGeohashGridIndex(GeohashGridOptions{Precision: precision}).Load(pts[:1_500]...).(*GeohashGrid)
+		grid.Add(pts[1_500:]...)
+		assertEqual(t, 500, grid.Remove(pts[1_000:1_500]...))
+		remaining := append(append([]Point{}, pts[:1_000]...), pts[1_500:]...)
+		kd := NewKDTreeIndex(DefaultKDTreeOptions()).Load(remaining...)
+
+		origins := []Point{NewCoordinates(0, 90), NewCoordinates(179.99, 0), NewCoordinates(-122.05, 47.05)}
+		for trial := 0; trial < 10; trial++ {
+			origins = append(origins, NewCoordinates(rnd.Float64()*360-180, rnd.Float64()*180-90))
+		}
+		for _, origin := range origins {
+			expected, results := kd.Nearby(origin, 25, AcceptAny), grid.Nearby(origin, 25, AcceptAny)
+			assertEqual(t, len(expected), len(results))
+			for i := range expected {
+				assertEqual(t, expected[i], results[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package neighborhood
+
+import (
+	"math"
+	"sort"
+)
+
+// NearbyGrouped finds the k nearest Points for each group of Points that meet the Accepter criteria, in a single
+// search. The group function gets the group of a Point (like its ISP). If groups are given, only Points of those
+// groups are searched and the search stops as soon as each of them has k results; otherwise groups are discovered
+// during the search, which skips the Points of groups that already have k results but has to search the whole Index.
+// Results are keyed by group, and each group's Points are ordered like Nearby, with ties (see TieEpsilonKm) formed
+// within the group.
+func (idx *KDTree) NearbyGrouped(origin Point, k int, accept Accepter, group func(p Point) string, groups ...string) map[string][]Point {
+	idx.RLock()
+	defer idx.RUnlock()
+
+	result := make(map[string][]Point)
+	if k <= 0 {
+		return result
+	}
+
+	requestedGroups := make(map[string]bool)
+	for _, g := range groups {
+		requestedGroups[g] = true
+	}
+	unsatisfied := len(requestedGroups)
+	requested := accept
+	accept = func(p Point) bool {
+		g := group(p)
+		if len(groups) > 0 && !requestedGroups[g] {
+			return false
+		}
+		return len(result[g]) < k && requested(p)
+	}
+
+	tieEnd := idx.nearbyTieEnd()
+	if tieEnd == nil {
+		// only points at exactly the same distance are tied
+		tieEnd = func(dist float64) float64 { return dist }
+	}
+	search := idx.nearbySearch(origin, accept)
+	search.tieEnd = nil // ties are formed within each group instead
+
+	// the points of each group that may still be tied with later points of the group, in order of distance
+	queued := make(map[string][]*item)
+
+	// flush moves the queued points of a group to its results, one group of tied points at a time, once no point
+	// at dist or farther can be tied with them
+	flush := func(g string, dist float64) {
+		q := queued[g]
+		for len(q) > 0 && len(result[g]) < k {
+			end := tieEnd(q[0].distance)
+			if end >= dist {
+				break
+			}
+			n := 1
+			for n < len(q) && q[n].distance <= end {
+				n++
+			}
+			// prefer the highest rank within the tied points, then the closest, then the earliest inserted
+			tied := q[:n]
+			sort.Slice(tied, func(i, j int) bool {
+				if tied[i].rank != tied[j].rank {
+					return tied[i].rank > tied[j].rank
+				}
+				return tied[i].before(tied[j])
+			})
+			for _, itm := range tied {
+				if len(result[g]) < k {
+					result[g] = append(result[g], itm.point)
+				}
+			}
+			q = q[n:]
+		}
+		if len(q) == 0 || len(result[g]) == k {
+			delete(queued, g)
+			if len(result[g]) == k && len(groups) > 0 {
+				unsatisfied--
+			}
+			return
+		}
+		queued[g] = q
+	}
+
+	for len(groups) == 0 || unsatisfied > 0 {
+		itm := search.next()
+		dist := math.Inf(1)
+		if itm != nil {
+			dist = itm.distance
+		}
+		for g := range queued {
+			flush(g, dist)
+		}
+		if itm == nil {
+			break
+		}
+		if g := group(itm.point); len(result[g]) < k {
+			// otherwise this group was satisfied after the point was queued
+			queued[g] = append(queued[g], itm)
+		}
+	}
+	return result
+}
@@ -0,0 +1,95 @@
+package neighborhood
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestKDTree_NearbyGrouped(t *testing.T) {
+	idx := NewIndex().Load(dataCenterPoints()...).(*KDTree)
+	origin := NewCoordinates(-122.3, 47.6)
+	dataCenter := func(p Point) string { return p.(*DataCenterPoint).DataCenter }
+
+	results := idx.NearbyGrouped(origin, 2, AcceptAny, dataCenter)
+	assertEqual(t, 3, len(results))
+	assertEqual(t, 2, len(results["sea-a"]))
+	assertEqual(t, "sea-a-2", results["sea-a"][0].(*DataCenterPoint).Name)
+	assertEqual(t, "sea-a-1", results["sea-a"][1].(*DataCenterPoint).Name)
+	assertEqual(t, 2, len(results["sea-b"]))
+	assertEqual(t, "sea-b-0", results["sea-b"][0].(*DataCenterPoint).Name)
+	assertEqual(t, "sea-b-1", results["sea-b"][1].(*DataCenterPoint).Name)
+	// there is only one server in woodinville
+	assertEqual(t, 1, len(results["woodinville"]))
+
+	results = idx.NearbyGrouped(origin, 1, func(p Point) bool {
+		return p.(*DataCenterPoint).Name != "sea-b-0"
+	}, dataCenter)
+	assertEqual(t, 3, len(results))
+	assertEqual(t, "sea-b-1", results["sea-b"][0].(*DataCenterPoint).Name)
+
+	assertEqual(t, 0, len(idx.NearbyGrouped(origin, 0, AcceptAny, dataCenter)))
+}
+
+func TestKDTree_NearbyGrouped_Requested(t *testing.T) {
+	idx := NewIndex().Load(dataCenterPoints()...).(*KDTree)
+	origin := NewCoordinates(-122.3, 47.6)
+	dataCenter := func(p Point) string { return p.(*DataCenterPoint).DataCenter }
+
+	results := idx.NearbyGrouped(origin, 5, AcceptAny, dataCenter, "woodinville", "sea-a", "nowhere", "sea-a")
+	assertEqual(t, 2, len(results))
+	assertEqual(t, 3, len(results["sea-a"]))
+	assertEqual(t, 1, len(results["woodinville"]))
+	assertEqual(t, 0, len(results["sea-b"]))
+}
+
+func TestKDTree_NearbyGrouped_Global(t *testing.T) {
+	idx := NewKDTreeIndex(KDTreeOptions{NodeSize: 8}).Load(globalPoints(10_000)...).(*KDTree)
+	origin := NewCoordinates(-122, 47)
+	hemisphere := func(p Point) string {
+		if p.Lat() < 0 {
+			return "south"
+		}
+		return "north"
+	}
+
+	// Points of satisfied groups are skipped without calling the Accepter
+	accepted := 0
+	results := idx.NearbyGrouped(origin, 3, func(p Point) bool {
+		accepted++
+		return true
+	}, hemisphere)
+	assertEqual(t, true, accepted < 5_000)
+	assertEqual(t, 2, len(results))
+	for g, pts := range results {
+		expected := idx.Nearby(origin, 3, func(p Point) bool { return hemisphere(p) == g })
+		assertEqual(t, len(expected), len(pts))
+		for i := range expected {
+			assertEqual(t, expected[i], pts[i])
+		}
+	}
+}
+
+func TestKDTree_NearbyGrouped_TieEpsilon(t *testing.T) {
+	// each group's results are tied within the group, like a Nearby search that only accepts the group
+	quadrant := func(p Point) string { return fmt.Sprint(p.Lon() < 0, p.Lat() < 0) }
+	for seed := int64(1); seed <= 200; seed++ {
+		rnd := rand.New(rand.NewSource(seed))
+		pts := randomTestPoints(rnd, 1+rnd.Intn(300))
+		origin := randomTestPoint(rnd, pts)
+		k := 1 + rnd.Intn(20)
+		opts := KDTreeOptions{NodeSize: 4, TieEpsilonKm: 100 + 1000*rnd.Float64()}
+		idx := NewKDTreeIndex(opts).Load(pts...).(*KDTree)
+
+		var groups []string
+		if seed%2 == 0 {
+			groups = []string{"false false", "true true"}
+		}
+		for g, results := range idx.NearbyGrouped(origin, k, AcceptAny, quadrant, groups...) {
+			expected := idx.Nearby(origin, k, func(p Point) bool { return quadrant(p) == g })
+			if failure := compareOrdered(fmt.Sprintf("seed %d: group %s", seed, g), expected, results); failure != "" {
+				t.Fatal(failure)
+			}
+		}
+	}
+}
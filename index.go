@@ -6,8 +6,9 @@ package neighborhood
 type Index interface {
 	// Nearby finds the k nearest Points to the origin that meet the Accepter criteria.
 	// If there are multiple Points that are the same distance from the origin and the Points implement the Ranker
-	// interface, the higher ranking Points will be preferred. Nearby may return less than k results if it cannot
-	// find k Points in the Index that meet the Accepter criteria.
+	// interface, the higher ranking Points will be preferred. Points with the same distance and rank are returned in the
+	// order they were loaded (by Load, followed by Add). Nearby may return less than k results if it cannot find k
+	// Points in the Index that meet the Accepter criteria.
 	Nearby(p Point, k int, accept Accepter) []Point
 
 	// Load will replace all Points in the Index with the provided Points.
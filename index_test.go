@@ -2,6 +2,8 @@ package neighborhood
 
 import (
 	"fmt"
+	"math"
+	"math/rand"
 	"testing"
 )
 
@@ -279,3 +281,285 @@ func TestKDTree_Load_Less(t *testing.T) {
 	assertEqual(t, "seattle", results[0].(*NamedPoint).Name)
 	assertEqual(t, "woodinville", results[1].(*NamedPoint).Name)
 }
+
+func TestKDTree_Within(t *testing.T) {
+	pts := namedPoints()
+	idx := NewIndex().Load(pts...).(*KDTree)
+	origin := NewCoordinates(-122, 47)
+
+	results := idx.Within(origin, 100, AcceptAny)
+	assertEqual(t, 2, len(results))
+	for _, result := range results {
+		name := result.(*NamedPoint).Name
+		assertEqual(t, true, name == "seattle" || name == "woodinville")
+	}
+
+	results = idx.Within(origin, 100, func(pt Point) bool {
+		return pt.(*NamedPoint).Name != "seattle"
+	})
+	assertEqual(t, 1, len(results))
+	assertEqual(t, "woodinville", results[0].(*NamedPoint).Name)
+
+	assertEqual(t, 0, len(idx.Within(origin, -1, AcceptAny)))
+	assertEqual(t, len(pts), len(idx.Within(origin, 50_000, AcceptAny)))
+}
+
+func TestKDTree_Within_AntiMeridian(t *testing.T) {
+	pts := namedPoints()
+	// use a small NodeSize so our results must come from multiple nodes
+	idx := NewKDTreeIndex(KDTreeOptions{NodeSize: 2}).Load(pts...).(*KDTree)
+	origin := NewCoordinates(179.5, 63)
+
+	results := idx.Within(origin, 2000, AcceptAny)
+	assertEqual(t, 2, len(results))
+	for _, result := range results {
+		name := result.(*NamedPoint).Name
+		assertEqual(t, true, name == "eastrussia" || name == "anchorage")
+	}
+}
+
+func TestKDTree_Within_Global(t *testing.T) {
+	pts := globalPoints(10_000)
+	idx := NewIndex().Load(pts...).(*KDTree)
+	origin := NewCoordinates(-122, 47)
+
+	expected := 0
+	for _, pt := range pts {
+		if distanceKm(origin, pt) <= 1000 {
+			expected++
+		}
+	}
+	assertEqual(t, expected, len(idx.Within(origin, 1000, AcceptAny)))
+}
+
+func TestKDTree_Range(t *testing.T) {
+	pts := namedPoints()
+	// use a small NodeSize so our results must come from multiple nodes
+	idx := NewKDTreeIndex(KDTreeOptions{NodeSize: 2}).Load(pts...).(*KDTree)
+
+	results := idx.Range(-125, 45, -120, 50, AcceptAny)
+	assertEqual(t, 2, len(results))
+	for _, result := range results {
+		name := result.(*NamedPoint).Name
+		assertEqual(t, true, name == "seattle" || name == "woodinville")
+	}
+
+	results = idx.Range(-125, 45, -120, 50, func(pt Point) bool {
+		return pt.(*NamedPoint).Name != "seattle"
+	})
+	assertEqual(t, 1, len(results))
+	assertEqual(t, "woodinville", results[0].(*NamedPoint).Name)
+
+	assertEqual(t, len(pts), len(idx.Range(-180, -90, 180, 90, AcceptAny)))
+	assertEqual(t, 0, len(idx.Range(0, -10, 10, 0, AcceptAny)))
+}
+
+func TestKDTree_Range_AntiMeridian(t *testing.T) {
+	pts := namedPoints()
+	idx := NewKDTreeIndex(KDTreeOptions{NodeSize: 2}).Load(pts...).(*KDTree)
+
+	// crosses the date line from eastern russia to anchorage
+	results := idx.Range(170, 55, -145, 65, AcceptAny)
+	assertEqual(t, 2, len(results))
+	for _, result := range results {
+		name := result.(*NamedPoint).Name
+		assertEqual(t, true, name == "eastrussia" || name == "anchorage")
+	}
+}
+
+func TestKDTree_Farthest(t *testing.T) {
+	pts := namedPoints()
+	idx := NewKDTreeIndex(KDTreeOptions{NodeSize: 2}).Load(pts...).(*KDTree)
+	origin := NewCoordinates(-122, 47)
+
+	results := idx.Farthest(origin, 3, AcceptAny)
+	assertEqual(t, 3, len(results))
+	// results are sorted by decreasing distance
+	for i := 1; i < len(results); i++ {
+		assertEqual(t, true, distanceKm(origin, results[i-1]) >= distanceKm(origin, results[i]))
+	}
+	for _, pt := range pts {
+		if distanceKm(origin, pt) > distanceKm(origin, results[0]) {
+			t.Errorf("%s is farther than %s", pt.(*NamedPoint).Name, results[0].(*NamedPoint).Name)
+		}
+	}
+
+	results = idx.Farthest(origin, 10, func(pt Point) bool {
+		return pt.(*NamedPoint).Name != "cairo"
+	})
+	assertEqual(t, 7, len(results))
+	assertEqual(t, "seattle", results[6].(*NamedPoint).Name)
+}
+
+func TestKDTree_Farthest_Global(t *testing.T) {
+	pts := globalPoints(10_000)
+	idx := NewIndex().Load(pts...).(*KDTree)
+	origin := NewCoordinates(-122, 47)
+
+	farthest := 0.0
+	for _, pt := range pts {
+		farthest = math.Max(farthest, distanceKm(origin, pt))
+	}
+
+	results := idx.Farthest(origin, 5, AcceptAny)
+	assertEqual(t, 5, len(results))
+	assertEqual(t, farthest, distanceKm(origin, results[0]))
+	for _, result := range results {
+		// the farthest points are around the antipode, about half the Earth's circumference away
+		assertEqual(t, true, distanceKm(origin, result) > 19_500)
+	}
+}
+
+func TestKDTree_Farthest_TiedAtPole(t *testing.T) {
+	// both Points at the south pole are at the same distance, but rounding made the lower bound of the node with
+	// the first one larger than that distance, so the second one used to be returned first
+	pts := []Point{
+		&RankedPoint{Point: NewCoordinates(-179.96687182147394, -90), Name: "first", Rank: 1},
+		&RankedPoint{Point: NewCoordinates(-26.55848609173802, 51.58564833424356), Rank: 1},
+		&RankedPoint{Point: NewCoordinates(-158.03408110610636, -7.546911262092479), Rank: 2},
+		&RankedPoint{Point: NewCoordinates(-154.07706189998495, 23.419961534205427), Rank: 1},
+		&RankedPoint{Point: NewCoordinates(-179.9971621243862, 89.95822878155138), Rank: 1},
+		&RankedPoint{Point: NewCoordinates(179.9948180657775, -90), Name: "second", Rank: 1},
+	}
+	idx := NewKDTreeIndex(KDTreeOptions{NodeSize: 4}).Load(pts...).(*KDTree)
+
+	results := idx.Farthest(NewCoordinates(-179.99116715129787, 89.95962176976617), 2, AcceptAny)
+	assertEqual(t, "first", results[0].(*RankedPoint).Name)
+	assertEqual(t, "second", results[1].(*RankedPoint).Name)
+}
+
+func TestKDTree_CountWithin(t *testing.T) {
+	pts := globalPoints(10_000)
+	idx := NewKDTreeIndex(KDTreeOptions{NodeSize: 8}).Load(pts...).(*KDTree)
+
+	for _, origin := range []Point{NewCoordinates(-122, 47), NewCoordinates(179.9, 0), NewCoordinates(0, 89.9)} {
+		for _, radiusKm := range []float64{-1, 0, 100, 1_000, 5_000, 25_000} {
+			expected := len(idx.Within(origin, radiusKm, AcceptAny))
+			assertEqual(t, expected, idx.CountWithin(origin, radiusKm))
+		}
+	}
+	assertEqual(t, len(pts), idx.CountWithin(NewCoordinates(0, 0), 25_000))
+	assertEqual(t, 0, NewIndex().(*KDTree).CountWithin(NewCoordinates(0, 0), 25_000))
+}
+
+func TestKDTree_CountRange(t *testing.T) {
+	pts := globalPoints(10_000)
+	idx := NewKDTreeIndex(KDTreeOptions{NodeSize: 8}).Load(pts...).(*KDTree)
+
+	boxes := [][4]float64{
+		{-125, 45, -120, 50},
+		{-180, -90, 180, 90},
+		{170, -10, -170, 10}, // crosses the date line
+		{0, 0, 0, 0},
+		{-90, -45, 90, 45},
+	}
+	for _, b := range boxes {
+		expected := len(idx.Range(b[0], b[1], b[2], b[3], AcceptAny))
+		assertEqual(t, expected, idx.CountRange(b[0], b[1], b[2], b[3]))
+	}
+	assertEqual(t, len(pts), idx.CountRange(-180, -90, 180, 90))
+}
+
+func TestKDTree_Nearby_TieEpsilon(t *testing.T) {
+	pts := []Point{
+		// the same building, a few meters apart
+		&RankedPoint{Point: NewCoordinates(-122.40000, 47.6), Name: "seattle-low", Rank: 1},
+		&RankedPoint{Point: NewCoordinates(-122.40003, 47.6), Name: "seattle-high", Rank: 5},
+		&RankedPoint{Point: NewCoordinates(-122.40006, 47.6), Name: "seattle-mid", Rank: 3},
+		&RankedPoint{Point: points["woodinville"], Name: "woodinville-super-important", Rank: 5000},
+	}
+	origin := NewCoordinates(-122.3, 47.6)
+
+	// by default, rank only breaks exact ties
+	idx := NewIndex().Load(pts...)
+	results := idx.Nearby(origin, 4, AcceptAny)
+	assertEqual(t, "seattle-low", results[0].(*RankedPoint).Name)
+	assertEqual(t, "seattle-high", results[1].(*RankedPoint).Name)
+	assertEqual(t, "seattle-mid", results[2].(*RankedPoint).Name)
+
+	opts := DefaultKDTreeOptions()
+	opts.TieEpsilonKm = 0.01
+	idx = NewKDTreeIndex(opts).Load(pts...)
+	results = idx.Nearby(origin, 4, AcceptAny)
+	assertEqual(t, 4, len(results))
+	assertEqual(t, "seattle-high", results[0].(*RankedPoint).Name)
+	assertEqual(t, "seattle-mid", results[1].(*RankedPoint).Name)
+	assertEqual(t, "seattle-low", results[2].(*RankedPoint).Name)
+	// but distance still wins outside the tie tolerance, regardless of rank
+	assertEqual(t, "woodinville-super-important", results[3].(*RankedPoint).Name)
+
+	// ties are resolved before results are cut off at k
+	results = idx.Nearby(origin, 1, AcceptAny)
+	assertEqual(t, "seattle-high", results[0].(*RankedPoint).Name)
+}
+
+func TestKDTree_Nearby_TieEpsilon_Guarantee(t *testing.T) {
+	rnd := rand.New(rand.NewSource(5))
+	pts := make([]Point, 2_000)
+	for i := range pts {
+		pts[i] = &RankedPoint{
+			Point: NewCoordinates(-122+rnd.Float64(), 47+rnd.Float64()),
+			Rank:  float64(rnd.Intn(10)),
+		}
+	}
+	opts := KDTreeOptions{NodeSize: 8, TieEpsilonKm: 5}
+	idx := NewKDTreeIndex(opts).Load(pts...).(*KDTree)
+	origin := NewCoordinates(-121.5, 47.5)
+
+	results := idx.Nearby(origin, 100, AcceptAny)
+	assertEqual(t, 100, len(results))
+	returned := make(map[Point]bool)
+	for i, result := range results {
+		returned[result] = true
+		// no result is more than the tolerance farther than a later result
+		for _, later := range results[i+1:] {
+			assertEqual(t, true, distanceKm(origin, result) <= distanceKm(origin, later)+opts.TieEpsilonKm+1e-6)
+		}
+	}
+	// or than a point that was not returned
+	last := distanceKm(origin, results[len(results)-1])
+	for _, pt := range pts {
+		if !returned[pt] {
+			assertEqual(t, true, last <= distanceKm(origin, pt)+opts.TieEpsilonKm+1e-6)
+		}
+	}
+
+	results = idx.Farthest(origin, 10, AcceptAny)
+	assertEqual(t, 10, len(results))
+	for i := 1; i < len(results); i++ {
+		assertEqual(t, true, distanceKm(origin, results[i-1])+opts.TieEpsilonKm+1e-6 >= distanceKm(origin, results[i]))
+	}
+}
+
+func TestKDTree_Nearby_Deterministic(t *testing.T) {
+	// many points at the same location and rank, spread over many kd-tree nodes
+	var pts []Point
+	for i := 0; i < 50; i++ {
+		pts = append(pts, &RankedPoint{Point: points["seattle"], Name: fmt.Sprint(i)})
+		pts = append(pts, &RankedPoint{Point: points["memphis"], Name: fmt.Sprint("memphis-", i)})
+	}
+	origin := NewCoordinates(-122, 47)
+	opts := KDTreeOptions{NodeSize: 2}
+
+	// equal points come back in insertion order
+	loaded := NewKDTreeIndex(opts).Load(pts...).Nearby(origin, 20, AcceptAny)
+	for i, result := range loaded {
+		assertEqual(t, fmt.Sprint(i), result.(*RankedPoint).Name)
+	}
+
+	// regardless of how the points were loaded
+	idx := NewKDTreeIndex(opts).Load(pts[:7]...)
+	idx.Add(pts[7:30]...)
+	idx.Add(pts[30:]...)
+	added := idx.Nearby(origin, 20, AcceptAny)
+	for i := range loaded {
+		assertEqual(t, loaded[i], added[i])
+	}
+
+	// and regardless of the tie tolerance
+	opts.TieEpsilonKm = 1
+	tolerant := NewKDTreeIndex(opts).Load(pts...).Nearby(origin, 20, AcceptAny)
+	for i := range loaded {
+		assertEqual(t, loaded[i], tolerant[i])
+	}
+}
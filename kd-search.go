@@ -0,0 +1,308 @@
+package neighborhood
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+// kdSearch is a best-first traversal of the kd-tree that yields points in order of increasing distance.
+// Distances can be any measure, as long as a node's distance is a lower bound of the distances to its points.
+// The caller is responsible for locking the KDTree for the lifetime of the search.
+type kdSearch struct {
+	idx       *KDTree
+	accept    Accepter
+	pointDist func(i int) float64            // distance to the point at a kd-tree array index, or +Inf to skip it
+	nodeDist  func(node *kdTreeNode) float64 // lower bound of distances to the points inside a node, or +Inf to skip it
+
+	// tieEnd optionally gets the farthest distance that is tied with a given distance (see KDTreeOptions)
+	tieEnd func(dist float64) float64
+
+	// a distance-sorted rank queue that will contain both points and kd-tree nodes
+	q priorityQueue
+
+	// tied points that are ready to be returned, in order
+	tied []*item
+}
+
+// newSearch creates a search starting at the top kd-tree node (the whole Earth)
+func (idx *KDTree) newSearch(accept Accepter, pointDist func(i int) float64, nodeDist func(node *kdTreeNode) float64) *kdSearch {
+	s := &kdSearch{
+		idx:       idx,
+		accept:    accept,
+		pointDist: pointDist,
+		nodeDist:  nodeDist,
+		q:         newPriorityQueue(idx.nodeSize),
+	}
+	s.pushNode(idx.root())
+	return s
+}
+
+// next gets the next closest point item, or nil if there are no more points.
+// Points are guaranteed to be closer than all remaining points (both individual and those in kd-tree nodes),
+// since each node's distance is a lower bound of distances to its children.
+func (s *kdSearch) next() *item {
+	if len(s.tied) > 0 {
+		itm := s.tied[0]
+		s.tied = s.tied[1:]
+		return itm
+	}
+
+	itm := s.nextClosest()
+	if itm == nil || s.tieEnd == nil {
+		return itm
+	}
+
+	// gather every point that is tied with the closest one; nodes within the tie distance may hold more of them
+	end := s.tieEnd(itm.distance)
+	group := []*item{itm}
+	for s.q.Len() > 0 && s.q.Peek().distance <= end {
+		if tied := s.q.PopItem(); tied.point != nil {
+			group = append(group, tied)
+		} else {
+			s.expand(tied.node.(*kdTreeNode))
+		}
+	}
+
+	// prefer the highest rank within the group, then the closest, then the earliest inserted
+	sort.Slice(group, func(i, j int) bool {
+		if group[i].rank != group[j].rank {
+			return group[i].rank > group[j].rank
+		}
+		return group[i].before(group[j])
+	})
+	s.tied = group[1:]
+	return group[0]
+}
+
+// nextClosest gets the next closest point item regardless of ties, or nil if there are no more points
+func (s *kdSearch) nextClosest() *item {
+	for {
+		itm := s.q.PopItem()
+		if itm == nil || itm.point != nil {
+			return itm
+		}
+		s.expand(itm.node.(*kdTreeNode))
+	}
+}
+
+// take gets up to k of the next closest points
+func (s *kdSearch) take(k int) []Point {
+	result := make([]Point, 0, k)
+	for len(result) < k {
+		itm := s.next()
+		if itm == nil {
+			break
+		}
+		result = append(result, itm.point)
+	}
+	return result
+}
+
+// expand adds the points and child nodes of a kd-tree node to the queue
+func (s *kdSearch) expand(node *kdTreeNode) {
+	idx := s.idx
+	if idx.isLeaf(node) {
+		// add all points of the leaf node to the queue
+		for i := node.Left; i <= node.Right; i++ {
+			s.pushPoint(i)
+		}
+		return
+	}
+
+	// not a leaf node (has child nodes), so add the middle point and both halves to the queue
+	m, leftNode, rightNode := idx.split(node)
+	s.pushPoint(m)
+
+	s.pushNode(leftNode)
+	s.pushNode(rightNode)
+}
+
+// pushNode adds a kd-tree node to the queue, unless its distance is infinite because it cannot hold any results
+func (s *kdSearch) pushNode(node *kdTreeNode) {
+	if node.Dist = s.nodeDist(node); !math.IsInf(node.Dist, 1) {
+		s.q.PushNode(node)
+	}
+}
+
+// pushPoint adds the point at a kd-tree array index to the queue if it meets the Accepter criteria,
+// unless its distance is infinite because it cannot be a result
+func (s *kdSearch) pushPoint(i int) {
+	if pt := s.idx.points[s.idx.ids[i]]; s.accept(pt) {
+		if dist := s.pointDist(i); !math.IsInf(dist, 1) {
+			s.q.PushPoint(pt, dist, s.idx.ids[i])
+		}
+	}
+}
+
+// mergedSearch yields the points of several searches in order of increasing distance.
+// The caller is responsible for locking the KDTree of every search.
+type mergedSearch struct {
+	searches []*kdSearch
+	seqs     [][]int         // insertion order of each search's points among all searches, if known
+	heads    mergeQueue      // the next point of each search that has one
+	pending  []pendingSearch // searches that have not started yet, by increasing lower bound
+
+	// tieEnd optionally gets the farthest distance that is tied with a given distance, across all searches
+	tieEnd func(dist float64) float64
+
+	// tied points that are ready to be returned, in order
+	tied []mergeHead
+}
+
+// mergeHead is the next point of a search
+type mergeHead struct {
+	itm    *item
+	search int
+	seq    int // insertion order of the point among all searches, or 0 if unknown
+}
+
+// pendingSearch is a search that only needs to start once the merged search reaches its lower bound
+type pendingSearch struct {
+	bound float64          // lower bound for the distance of the search's points
+	seqs  []int            // insertion order of the search's points among all searches
+	start func() *kdSearch // starts the search
+}
+
+func newMergedSearch(searches []*kdSearch) *mergedSearch {
+	ms := &mergedSearch{}
+	for _, s := range searches {
+		ms.add(s, nil)
+	}
+	return ms
+}
+
+// newPendingMergedSearch creates a merged search that starts each search when its lower bound is reached,
+// and groups points of all searches that are tied by tieEnd (which may be nil)
+func newPendingMergedSearch(pending []pendingSearch, tieEnd func(dist float64) float64) *mergedSearch {
+	sort.SliceStable(pending, func(i, j int) bool { return pending[i].bound < pending[j].bound })
+	return &mergedSearch{pending: pending, tieEnd: tieEnd}
+}
+
+// add adds a started search, with the insertion order of its points among all searches if known
+func (ms *mergedSearch) add(s *kdSearch, seqs []int) {
+	if ms.tieEnd != nil {
+		// ties are grouped across all searches instead
+		s.tieEnd = nil
+	}
+	ms.searches = append(ms.searches, s)
+	ms.seqs = append(ms.seqs, seqs)
+	if itm := s.next(); itm != nil {
+		heap.Push(&ms.heads, ms.head(itm, len(ms.searches)-1))
+	}
+}
+
+func (ms *mergedSearch) head(itm *item, search int) mergeHead {
+	head := mergeHead{itm: itm, search: search}
+	if seqs := ms.seqs[search]; seqs != nil {
+		head.seq = seqs[itm.seq]
+	}
+	return head
+}
+
+// next gets the next closest point item of all searches, or nil if there are no more points
+func (ms *mergedSearch) next() *item {
+	if len(ms.tied) > 0 {
+		head := ms.tied[0]
+		ms.tied = ms.tied[1:]
+		return head.itm
+	}
+
+	ms.startPending(math.Inf(-1))
+	if ms.heads.Len() == 0 {
+		return nil
+	}
+	closest := ms.pop()
+	if ms.tieEnd == nil {
+		return closest.itm
+	}
+
+	// gather every point of all searches that is tied with the closest one
+	end := ms.tieEnd(closest.itm.distance)
+	group := []mergeHead{closest}
+	for ms.startPending(end); ms.heads.Len() > 0 && ms.heads[0].itm.distance <= end; ms.startPending(end) {
+		group = append(group, ms.pop())
+	}
+
+	// prefer the highest rank within the group, then the closest, then the earliest inserted
+	sort.Slice(group, func(i, j int) bool {
+		if group[i].itm.rank != group[j].itm.rank {
+			return group[i].itm.rank > group[j].itm.rank
+		}
+		return group[i].before(group[j])
+	})
+	ms.tied = group[1:]
+	return group[0].itm
+}
+
+// startPending starts the pending searches that may have points as close as the closest head, or within dist
+func (ms *mergedSearch) startPending(dist float64) {
+	for len(ms.pending) > 0 &&
+		(ms.heads.Len() == 0 || ms.pending[0].bound <= math.Max(dist, ms.heads[0].itm.distance)) {
+		p := ms.pending[0]
+		ms.pending = ms.pending[1:]
+		ms.add(p.start(), p.seqs)
+	}
+}
+
+// pop removes the closest head and replaces it with the next point of its search
+func (ms *mergedSearch) pop() mergeHead {
+	head := ms.heads[0]
+	if itm := ms.searches[head.search].next(); itm != nil {
+		ms.heads[0] = ms.head(itm, head.search)
+		heap.Fix(&ms.heads, 0)
+	} else {
+		heap.Pop(&ms.heads)
+	}
+	return head
+}
+
+// take gets up to k of the next closest points
+func (ms *mergedSearch) take(k int) []Point {
+	result := make([]Point, 0, k)
+	for len(result) < k {
+		itm := ms.next()
+		if itm == nil {
+			break
+		}
+		result = append(result, itm.point)
+	}
+	return result
+}
+
+// mergeQueue implements heap.Interface and holds the next point of each search
+type mergeQueue []mergeHead
+
+func (mq mergeQueue) Less(i, j int) bool {
+	a, b := mq[i].itm, mq[j].itm
+	if a.distance == b.distance && a.rank != b.rank {
+		return a.rank > b.rank
+	}
+	return mq[i].before(mq[j])
+}
+
+// before orders heads by distance, then by insertion order
+func (h mergeHead) before(other mergeHead) bool {
+	if h.itm.distance != other.itm.distance {
+		return h.itm.distance < other.itm.distance
+	}
+	if h.seq != other.seq {
+		return h.seq < other.seq
+	}
+	// otherwise insertion order is only meaningful within a search, so prefer earlier searches
+	return h.search < other.search
+}
+
+func (mq mergeQueue) Len() int { return len(mq) }
+
+func (mq mergeQueue) Swap(i, j int) { mq[i], mq[j] = mq[j], mq[i] }
+
+func (mq *mergeQueue) Push(x interface{}) { *mq = append(*mq, x.(mergeHead)) }
+
+func (mq *mergeQueue) Pop() interface{} {
+	old := *mq
+	n := len(old)
+	head := old[n-1]
+	*mq = old[0 : n-1]
+	return head
+}
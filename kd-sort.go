@@ -2,7 +2,8 @@ package neighborhood
 
 import "math"
 
-func kdSort(ids []int, coords []float64, nodeSize, left, right, axis int) {
+// kdSort sorts ids and coords (with dims coordinates per point) into a kd-tree, splitting on each axis in turn
+func kdSort(ids []int, coords []float64, dims, nodeSize, left, right, axis int) {
 	if right-left < nodeSize {
 		return
 	}
@@ -10,16 +11,16 @@ func kdSort(ids []int, coords []float64, nodeSize, left, right, axis int) {
 
 	// sort ids and coords around the middle index so that the halves lie
 	// either left/right or top/bottom correspondingly (taking turns)
-	selection(ids, coords, m, left, right, axis)
+	selection(ids, coords, dims, m, left, right, axis)
 
-	// recursively kd-sort first half and second half on the opposite axis
-	kdSort(ids, coords, nodeSize, left, m-1, 1-axis)
-	kdSort(ids, coords, nodeSize, m+1, right, 1-axis)
+	// recursively kd-sort first half and second half on the next axis
+	kdSort(ids, coords, dims, nodeSize, left, m-1, (axis+1)%dims)
+	kdSort(ids, coords, dims, nodeSize, m+1, right, (axis+1)%dims)
 }
 
 // selection is a custom Floyd-Rivest selection algorithm: sort ids and coords so that
-// [left..k-1] items are smaller than k-th item (on either x or y axis)
-func selection(ids []int, coords []float64, k, left, right, axis int) {
+// [left..k-1] items are smaller than k-th item (on the given axis)
+func selection(ids []int, coords []float64, dims, k, left, right, axis int) {
 	for right > left {
 		if right-left > 600 {
 			n := float64(right - left + 1)
@@ -33,34 +34,34 @@ func selection(ids []int, coords []float64, k, left, right, axis int) {
 			sd := 0.5 * math.Sqrt(z*s*(n-s)/n) * sign
 			newLeft := int(math.Max(float64(left), math.Floor(float64(k)-m*s/n+sd)))
 			newRight := int(math.Min(float64(right), math.Floor(float64(k)+(n-m)*s/n+sd)))
-			selection(ids, coords, k, newLeft, newRight, axis)
+			selection(ids, coords, dims, k, newLeft, newRight, axis)
 		}
-		t := coords[2*k+axis]
+		t := coords[dims*k+axis]
 		i := left
 		j := right
 
-		swapItem(ids, coords, left, k)
-		if coords[2*right+axis] > t {
-			swapItem(ids, coords, left, right)
+		swapItem(ids, coords, dims, left, k)
+		if coords[dims*right+axis] > t {
+			swapItem(ids, coords, dims, left, right)
 		}
 
 		for i < j {
-			swapItem(ids, coords, i, j)
+			swapItem(ids, coords, dims, i, j)
 			i++
 			j--
-			for coords[2*i+axis] < t {
+			for coords[dims*i+axis] < t {
 				i++
 			}
-			for coords[2*j+axis] > t {
+			for coords[dims*j+axis] > t {
 				j--
 			}
 		}
 
-		if coords[2*left+axis] == t {
-			swapItem(ids, coords, left, j)
+		if coords[dims*left+axis] == t {
+			swapItem(ids, coords, dims, left, j)
 		} else {
 			j++
-			swapItem(ids, coords, j, right)
+			swapItem(ids, coords, dims, j, right)
 		}
 
 		if j <= k {
@@ -73,10 +74,11 @@ func selection(ids []int, coords []float64, k, left, right, axis int) {
 	}
 }
 
-func swapItem(ids []int, coords []float64, i, j int) {
+func swapItem(ids []int, coords []float64, dims, i, j int) {
 	swapInt(ids, i, j)
-	swapFloat(coords, 2*i, 2*j)
-	swapFloat(coords, 2*i+1, 2*j+1)
+	for d := 0; d < dims; d++ {
+		swapFloat(coords, dims*i+d, dims*j+d)
+	}
 }
 
 func swapInt(arr []int, i, j int) {
@@ -9,14 +9,46 @@ import (
 type KDTree struct {
 	sync.RWMutex
 	nodeSize int
+	tieKm    float64
+	altitude bool
+	attrs    []Attribute
 	points   []Point
 	ids      []int
 	coords   []float64
+
+	// highest Point rank of each node, built on demand after each Load (see scoring.go)
+	maxRanksOnce sync.Once
+	maxRanks     []float64
+
+	// categorical attribute summaries, built on each Load (see attributes.go)
+	attrIndexes []*attributeIndex
+
+	// Point altitudes by kd-tree array index and their range, built on each Load in altitude mode (see altitude.go)
+	alts   []float64
+	minAlt float64
+	maxAlt float64
 }
 
 // KDTreeOptions defines configurable options for the KDTree index
 type KDTreeOptions struct {
 	NodeSize int
+
+	// TieEpsilonKm is the distance in kilometers within which Points are considered tied, so that the higher ranking
+	// Points are preferred (see Ranker). Results are returned in groups of tied Points: each group starts at the
+	// closest remaining Point and includes every remaining Point within TieEpsilonKm of it, ordered by rank and then
+	// by distance. This guarantees that no result is more than TieEpsilonKm farther than any later result, or than
+	// any Point that was not returned. By default only Points at exactly the same distance are tied.
+	TieEpsilonKm float64
+
+	// Altitude makes Nearby searches use the 3D slant range between Points, including their altitude (see
+	// Altituder), instead of the great-circle distance. TieEpsilonKm then applies to the slant range.
+	// Only Nearby, NearbyDiverse and NearbyGrouped use the slant range; every other search (such as NearbyMatching,
+	// NearbyPath, NearbyScored, Farthest, Within and the Count methods) still uses the great-circle distance.
+	Altitude bool
+
+	// Attributes declares categorical Point attributes to summarize for each kd-tree node,
+	// so that NearbyMatching can skip nodes without matching Points.
+	Attributes []Attribute
 }
 
 // DefaultKDTreeOptions gets the default KDTree options, which you can use directly or modify before creating an Index
@@ -30,6 +62,9 @@ func DefaultKDTreeOptions() KDTreeOptions {
 func NewKDTreeIndex(opts KDTreeOptions) Index {
 	return &KDTree{
 		nodeSize: opts.NodeSize,
+		tieKm:    opts.TieEpsilonKm,
+		altitude: opts.Altitude,
+		attrs:    opts.Attributes,
 	}
 }
 
@@ -41,12 +76,12 @@ func (idx *KDTree) Load(points ...Point) Index {
 	defer idx.Unlock()
 
 	// extend or shrink to the length we need
-	if additional := len(points)-len(idx.points); additional > 0 {
+	if additional := len(points) - len(idx.points); additional > 0 {
 		idx.ids = append(idx.ids, make([]int, len(points)-len(idx.ids))...)
 		idx.coords = append(idx.coords, make([]float64, 2*len(points)-len(idx.coords))...)
 	} else if additional < 0 {
 		idx.ids = idx.ids[0:len(points)]
-		idx.coords = idx.coords[0:2*len(points)]
+		idx.coords = idx.coords[0 : 2*len(points)]
 	}
 
 	// store indices to the input array and coordinates in separate typed arrays
@@ -58,7 +93,10 @@ func (idx *KDTree) Load(points ...Point) Index {
 	idx.points = points
 
 	// kd-sort both arrays for efficient search (see comments in sort.go)
-	kdSort(idx.ids, idx.coords, idx.nodeSize, 0, len(idx.ids)-1, 0)
+	kdSort(idx.ids, idx.coords, 2, idx.nodeSize, 0, len(idx.ids)-1, 0)
+	idx.maxRanksOnce = sync.Once{}
+	idx.buildAttributes()
+	idx.buildAltitudes()
 	return idx
 }
 
@@ -70,115 +108,347 @@ func (idx *KDTree) Add(points ...Point) Index {
 
 // Nearby finds the k nearest Points to the origin that meet the Accepter criteria.
 // If there are multiple Points that are the same distance from the origin and the Points implement the Ranker
-// interface, the higher ranking Points will be preferred. Nearby may return less than k results if it cannot
-// find k Points in the Index that meet the Accepter criteria.
+// interface, the higher ranking Points will be preferred. Points with the same distance and rank are returned in the
+// order they were loaded (by Load, followed by Add). Nearby may return less than k results if it cannot find k
+// Points in the Index that meet the Accepter criteria.
 func (idx *KDTree) Nearby(origin Point, k int, accept Accepter) []Point {
 	idx.RLock()
 	defer idx.RUnlock()
 
-	result := make([]Point, 0, k)
+	return idx.nearbySearch(origin, accept).take(k)
+}
+
+// nearbySearch creates a search for the nearest points to the origin. The caller is responsible for locking.
+func (idx *KDTree) nearbySearch(origin Point, accept Accepter) *kdSearch {
+	if idx.altitude {
+		return idx.slantSearch(origin, accept)
+	}
+	cosLat := math.Cos(origin.Lat() * rad)
+	search := idx.newSearch(accept,
+		func(i int) float64 {
+			return haverSinDist(origin, idx.coords[2*i], idx.coords[2*i+1], cosLat)
+		},
+		func(node *kdTreeNode) float64 {
+			return boxDist(origin, cosLat, node.bounds)
+		},
+	)
+	search.tieEnd = idx.nearbyTieEnd()
+	return search
+}
+
+// Farthest finds the k farthest Points from the origin that meet the Accepter criteria, farthest first.
+// If there are multiple Points that are the same distance from the origin and the Points implement the Ranker
+// interface, the higher ranking Points will be preferred. Farthest may return less than k results if it cannot
+// find k Points in the Index that meet the Accepter criteria.
+func (idx *KDTree) Farthest(origin Point, k int, accept Accepter) []Point {
+	idx.RLock()
+	defer idx.RUnlock()
+
+	// the search pops the lowest distance first, so use the complement of the distance;
+	// the complement of a node's upper bound is a lower bound for the points inside it
+	cosLat := math.Cos(origin.Lat() * rad)
+	search := idx.newSearch(accept,
+		func(i int) float64 {
+			return 1 - haverSinDist(origin, idx.coords[2*i], idx.coords[2*i+1], cosLat)
+		},
+		func(node *kdTreeNode) float64 {
+			return 1 - boxMaxDist(origin, cosLat, node.bounds)
+		},
+	)
+	if idx.tieKm > 0 {
+		search.tieEnd = func(dist float64) float64 {
+			return 1 - kmToHaverSin(haverSinToKm(1-dist)-idx.tieKm)
+		}
+	}
+	return search.take(k)
+}
 
-	// a distance-sorted rank queue that will contain both points and kd-tree nodes
-	q := newPriorityQueue(k)
+// haverSinTieEnd gets the end of a group of tied points for searches by distance (haversine), or nil if only
+// points at exactly the same distance are tied
+func (idx *KDTree) haverSinTieEnd() func(dist float64) float64 {
+	if idx.tieKm <= 0 {
+		return nil
+	}
+	return func(dist float64) float64 {
+		return kmToHaverSin(haverSinToKm(dist) + idx.tieKm)
+	}
+}
 
-	// an object that represents the top kd-tree node (the whole Earth)
-	node := &kdTreeNode{
-		Left:   0,
-		Right:  len(idx.ids) - 1,
-		Axis:   0,
-		MinLon: -180,
-		MinLat: -90,
-		MaxLon: 180,
-		MaxLat: 90,
+// nearbyTieEnd gets the end of a group of tied points for nearbySearch, or nil if only points at exactly the same
+// distance are tied
+func (idx *KDTree) nearbyTieEnd() func(dist float64) float64 {
+	if idx.altitude && idx.tieKm > 0 {
+		return func(dist float64) float64 {
+			return dist + idx.tieKm
+		}
 	}
+	return idx.haverSinTieEnd()
+}
+
+// Within finds all Points within radiusKm of the origin that meet the Accepter criteria.
+// Points are returned in no particular order.
+func (idx *KDTree) Within(origin Point, radiusKm float64, accept Accepter) []Point {
+	idx.RLock()
+	defer idx.RUnlock()
 
+	var result []Point
+	idx.within(origin, kmToHaverSin(radiusKm), func(i int) {
+		if pt := idx.points[idx.ids[i]]; accept(pt) {
+			result = append(result, pt)
+		}
+	})
+	return result
+}
+
+// within calls fn with the kd-tree array index of every point that is within maxDist (haversine) of the origin.
+// The caller is responsible for locking.
+func (idx *KDTree) within(origin Point, maxDist float64, fn func(i int)) {
 	cosLat := math.Cos(origin.Lat() * rad)
+	stack := []*kdTreeNode{idx.root()}
+
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
 
-	for node != nil {
-		if node.Right-node.Left <= idx.nodeSize { // leaf node
-			// add all points of the leaf node to the queue
+		if idx.isLeaf(node) {
 			for i := node.Left; i <= node.Right; i++ {
-				pt := idx.points[idx.ids[i]]
-				if accept(pt) {
-					dist := haverSinDist(origin, idx.coords[2*i], idx.coords[2*i+1], cosLat)
-					q.PushPoint(pt, dist)
+				if haverSinDist(origin, idx.coords[2*i], idx.coords[2*i+1], cosLat) <= maxDist {
+					fn(i)
 				}
 			}
-		} else { // not a leaf node (has child nodes)
-			m := (node.Left + node.Right) >> 1 // middle index
-			midLon := idx.coords[2*m]
-			midLat := idx.coords[2*m+1]
-
-			// add middle point to the queue
-			pt := idx.points[idx.ids[m]]
-			if accept(pt) {
-				dist := haverSinDist(origin, midLon, midLat, cosLat)
-				q.PushPoint(pt, dist)
-			}
+			continue
+		}
+
+		m, leftNode, rightNode := idx.split(node)
+		if haverSinDist(origin, idx.coords[2*m], idx.coords[2*m+1], cosLat) <= maxDist {
+			fn(m)
+		}
+		// only descend into child nodes that may contain points within range
+		if boxDist(origin, cosLat, leftNode.bounds) <= maxDist {
+			stack = append(stack, leftNode)
+		}
+		if boxDist(origin, cosLat, rightNode.bounds) <= maxDist {
+			stack = append(stack, rightNode)
+		}
+	}
+}
 
-			nextAxis := (node.Axis + 1) % 2
+// Range finds all Points inside a bounding box that meet the Accepter criteria. If minLon is greater than maxLon,
+// the bounding box is considered to cross the date line. Points are returned in no particular order.
+func (idx *KDTree) Range(minLon, minLat, maxLon, maxLat float64, accept Accepter) []Point {
+	idx.RLock()
+	defer idx.RUnlock()
 
-			// first half of the node
-			leftNode := &kdTreeNode{
-				Left:   node.Left,
-				Right:  m - 1,
-				Axis:   nextAxis,
-				MinLon: node.MinLon,
-				MinLat: node.MinLat,
-			}
-			if node.Axis == 0 {
-				leftNode.MaxLon = midLon
-				leftNode.MaxLat = node.MaxLat
-			} else {
-				leftNode.MaxLon = node.MaxLon
-				leftNode.MaxLat = midLat
+	var result []Point
+	fn := func(i int) {
+		if pt := idx.points[idx.ids[i]]; accept(pt) {
+			result = append(result, pt)
+		}
+	}
+	if minLon > maxLon {
+		// split the bounding box at the date line
+		idx.inRange(minLon, minLat, 180, maxLat, fn)
+		idx.inRange(-180, minLat, maxLon, maxLat, fn)
+	} else {
+		idx.inRange(minLon, minLat, maxLon, maxLat, fn)
+	}
+	return result
+}
+
+// inRange calls fn with the kd-tree array index of every point inside the bounding box.
+// The caller is responsible for locking.
+func (idx *KDTree) inRange(minLon, minLat, maxLon, maxLat float64, fn func(i int)) {
+	inside := func(i int) bool {
+		lon, lat := idx.coords[2*i], idx.coords[2*i+1]
+		return lon >= minLon && lon <= maxLon && lat >= minLat && lat <= maxLat
+	}
+	stack := []*kdTreeNode{idx.root()}
+
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if idx.isLeaf(node) {
+			for i := node.Left; i <= node.Right; i++ {
+				if inside(i) {
+					fn(i)
+				}
 			}
+			continue
+		}
 
-			// second half of the node
-			rightNode := &kdTreeNode{
-				Left:   m + 1,
-				Right:  node.Right,
-				Axis:   nextAxis,
-				MaxLon: node.MaxLon,
-				MaxLat: node.MaxLat,
+		m, leftNode, rightNode := idx.split(node)
+		if inside(m) {
+			fn(m)
+		}
+		// only descend into child nodes that overlap the bounding box
+		if node.Axis == 0 {
+			if minLon <= idx.coords[2*m] {
+				stack = append(stack, leftNode)
 			}
-			if node.Axis == 0 {
-				rightNode.MinLon = midLon
-				rightNode.MinLat = node.MinLat
-			} else {
-				rightNode.MinLon = node.MinLon
-				rightNode.MinLat = midLat
+			if maxLon >= idx.coords[2*m] {
+				stack = append(stack, rightNode)
 			}
+		} else {
+			if minLat <= idx.coords[2*m+1] {
+				stack = append(stack, leftNode)
+			}
+			if maxLat >= idx.coords[2*m+1] {
+				stack = append(stack, rightNode)
+			}
+		}
+	}
+}
 
-			leftNode.Dist = boxDist(origin, cosLat, leftNode)
-			rightNode.Dist = boxDist(origin, cosLat, rightNode)
+// CountWithin counts the Points within radiusKm of the origin without collecting them.
+// Nodes of the kd-tree that are entirely within range are counted without visiting their Points.
+func (idx *KDTree) CountWithin(origin Point, radiusKm float64) int {
+	idx.RLock()
+	defer idx.RUnlock()
 
-			// add child nodes to the queue
-			q.PushNode(leftNode)
-			q.PushNode(rightNode)
+	maxDist := kmToHaverSin(radiusKm)
+	cosLat := math.Cos(origin.Lat() * rad)
+	inside := func(i int) bool {
+		return haverSinDist(origin, idx.coords[2*i], idx.coords[2*i+1], cosLat) <= maxDist
+	}
+	return idx.count(inside,
+		func(node *kdTreeNode) bool { return boxDist(origin, cosLat, node.bounds) <= maxDist },
+		func(node *kdTreeNode) bool { return boxMaxDist(origin, cosLat, node.bounds) <= maxDist },
+	)
+}
+
+// CountRange counts the Points inside a bounding box without collecting them. If minLon is greater than maxLon,
+// the bounding box is considered to cross the date line.
+// Nodes of the kd-tree that are entirely inside the bounding box are counted without visiting their Points.
+func (idx *KDTree) CountRange(minLon, minLat, maxLon, maxLat float64) int {
+	idx.RLock()
+	defer idx.RUnlock()
+
+	if minLon > maxLon {
+		// split the bounding box at the date line
+		return idx.countRange(bounds{minLon, minLat, 180, maxLat}) + idx.countRange(bounds{-180, minLat, maxLon, maxLat})
+	}
+	return idx.countRange(bounds{minLon, minLat, maxLon, maxLat})
+}
+
+// countRange counts the points inside a bounding box that does not cross the date line
+func (idx *KDTree) countRange(b bounds) int {
+	inside := func(i int) bool {
+		lon, lat := idx.coords[2*i], idx.coords[2*i+1]
+		return lon >= b.MinLon && lon <= b.MaxLon && lat >= b.MinLat && lat <= b.MaxLat
+	}
+	return idx.count(inside,
+		func(node *kdTreeNode) bool {
+			return node.MinLon <= b.MaxLon && node.MaxLon >= b.MinLon && node.MinLat <= b.MaxLat && node.MaxLat >= b.MinLat
+		},
+		func(node *kdTreeNode) bool {
+			return node.MinLon >= b.MinLon && node.MaxLon <= b.MaxLon && node.MinLat >= b.MinLat && node.MaxLat <= b.MaxLat
+		},
+	)
+}
+
+// count counts the points inside a query region. The region is described by whether it contains the point at a
+// kd-tree array index, whether it may overlap a node, and whether it contains a node entirely.
+// The caller is responsible for locking.
+func (idx *KDTree) count(inside func(i int) bool, overlaps, contains func(node *kdTreeNode) bool) int {
+	total := 0
+	stack := []*kdTreeNode{idx.root()}
+
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if node.Right < node.Left || !overlaps(node) {
+			continue
+		}
+		if contains(node) {
+			// count the whole node at once
+			total += node.Right - node.Left + 1
+			continue
 		}
 
-		// fetch closest points from the queue; they're guaranteed to be closer
-		// than all remaining points (both individual and those in kd-tree nodes),
-		// since each node's distance is a lower bound of distances to its children
-		for q.Len() > 0 && q.Peek().point != nil {
-			itm := q.PopItem()
-			result = append(result, itm.point)
-			if len(result) == k {
-				return result
+		if idx.isLeaf(node) {
+			for i := node.Left; i <= node.Right; i++ {
+				if inside(i) {
+					total++
+				}
 			}
+			continue
 		}
 
-		// the next closest kd-tree node
-		itm := q.PopItem()
-		if itm != nil && itm.node != nil {
-			node = itm.node
-		} else {
-			node = nil
+		m, leftNode, rightNode := idx.split(node)
+		if inside(m) {
+			total++
 		}
+		stack = append(stack, leftNode, rightNode)
 	}
+	return total
+}
 
-	return result
+// root gets the top kd-tree node, which covers the whole Earth
+func (idx *KDTree) root() *kdTreeNode {
+	return &kdTreeNode{
+		Left:  0,
+		Right: len(idx.ids) - 1,
+		Axis:  0,
+		bounds: bounds{
+			MinLon: -180,
+			MinLat: -90,
+			MaxLon: 180,
+			MaxLat: 90,
+		},
+	}
+}
+
+// isLeaf reports whether the node's points are stored without further splitting
+func (idx *KDTree) isLeaf(node *kdTreeNode) bool {
+	return node.Right-node.Left <= idx.nodeSize
+}
+
+// split gets the middle index of a non-leaf node and the two child nodes on either side of it
+func (idx *KDTree) split(node *kdTreeNode) (m int, leftNode, rightNode *kdTreeNode) {
+	m = (node.Left + node.Right) >> 1 // middle index
+	midLon := idx.coords[2*m]
+	midLat := idx.coords[2*m+1]
+
+	nextAxis := (node.Axis + 1) % 2
+
+	// first half of the node
+	leftNode = &kdTreeNode{
+		Left:  node.Left,
+		Right: m - 1,
+		Axis:  nextAxis,
+		bounds: bounds{
+			MinLon: node.MinLon,
+			MinLat: node.MinLat,
+		},
+	}
+	if node.Axis == 0 {
+		leftNode.MaxLon = midLon
+		leftNode.MaxLat = node.MaxLat
+	} else {
+		leftNode.MaxLon = node.MaxLon
+		leftNode.MaxLat = midLat
+	}
+
+	// second half of the node
+	rightNode = &kdTreeNode{
+		Left:  m + 1,
+		Right: node.Right,
+		Axis:  nextAxis,
+		bounds: bounds{
+			MaxLon: node.MaxLon,
+			MaxLat: node.MaxLat,
+		},
+	}
+	if node.Axis == 0 {
+		rightNode.MinLon = midLon
+		rightNode.MinLat = node.MinLat
+	} else {
+		rightNode.MinLon = node.MinLon
+		rightNode.MinLat = midLat
+	}
+	return m, leftNode, rightNode
 }
 
 // kdTreeNode defines a box of points in the kd-tree
@@ -188,9 +458,13 @@ type kdTreeNode struct {
 	Axis  int     // 0 for longitude axis and 1 for latitude axis
 	Dist  float64 // will hold the lower bound of children's distances to the query point
 
-	// bounding box of the node
+	bounds // bounding box of the node
+}
+
+// bounds defines a bounding box
+type bounds struct {
 	MinLon float64
 	MinLat float64
 	MaxLon float64
 	MaxLat float64
-}
\ No newline at end of file
+}
@@ -0,0 +1,282 @@
+package neighborhood
+
+import (
+	"math"
+	"sort"
+)
+
+// maxSegmentDeg is the longest great-circle segment (in degrees) that is kept in a single bounding box;
+// longer segments are split so their bounding boxes stay tight
+const maxSegmentDeg = 5.0
+
+// NearbyPath finds the k nearest Points to any part of a path that meet the Accepter criteria.
+// The path is a polyline of great-circle segments between consecutive Points (like a route or a flight path),
+// and the distance to a Point is its cross-track distance to the closest segment.
+// Consecutive antipodal Points are connected by an arbitrary great circle; add a Point between them to choose one.
+// If there are multiple Points that are the same distance from the path and the Points implement the Ranker
+// interface, the higher ranking Points will be preferred. NearbyPath may return less than k results if it cannot
+// find k Points in the Index that meet the Accepter criteria.
+func (idx *KDTree) NearbyPath(path []Point, k int, accept Accepter) []Point {
+	idx.RLock()
+	defer idx.RUnlock()
+
+	if len(path) == 0 {
+		return nil
+	}
+	segments := newSegments(path)
+	search := idx.newSearch(accept,
+		func(i int) float64 {
+			return pathDist(segments, idx.coords[2*i], idx.coords[2*i+1])
+		},
+		func(node *kdTreeNode) float64 {
+			return pathBoxDist(segments, node.bounds)
+		},
+	)
+	search.tieEnd = idx.haverSinTieEnd()
+	return search.take(k)
+}
+
+// Corridor finds all Points within widthKm of any part of a path that meet the Accepter criteria.
+// The path is a polyline of great-circle segments between consecutive Points (like a highway or an undersea cable).
+// Consecutive antipodal Points are connected by an arbitrary great circle, like in NearbyPath.
+// Points are ordered by how far along the path they are, from the first path Point to the last.
+func (idx *KDTree) Corridor(path []Point, widthKm float64, accept Accepter) []Point {
+	idx.RLock()
+	defer idx.RUnlock()
+
+	if len(path) == 0 {
+		return nil
+	}
+	segments := newSegments(path)
+	maxDist := kmToHaverSin(widthKm)
+
+	type located struct {
+		pt    Point
+		along float64 // angle along the path to the closest point on the path
+		dist  float64
+	}
+	var found []located
+	visit := func(i int) {
+		pt := idx.points[idx.ids[i]]
+		if !accept(pt) {
+			return
+		}
+		lon, lat := idx.coords[2*i], idx.coords[2*i+1]
+		if s, dist := closestSegment(segments, lon, lat); dist <= maxDist {
+			found = append(found, located{pt: pt, along: segments[s].along(toVec3(lon, lat)), dist: dist})
+		}
+	}
+
+	stack := []*kdTreeNode{idx.root()}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if idx.isLeaf(node) {
+			for i := node.Left; i <= node.Right; i++ {
+				visit(i)
+			}
+			continue
+		}
+
+		m, leftNode, rightNode := idx.split(node)
+		visit(m)
+		// only descend into child nodes that may contain points within the corridor
+		if pathBoxDist(segments, leftNode.bounds) <= maxDist {
+			stack = append(stack, leftNode)
+		}
+		if pathBoxDist(segments, rightNode.bounds) <= maxDist {
+			stack = append(stack, rightNode)
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].along == found[j].along {
+			return found[i].dist < found[j].dist
+		}
+		return found[i].along < found[j].along
+	})
+	result := make([]Point, len(found))
+	for i, l := range found {
+		result[i] = l.pt
+	}
+	return result
+}
+
+// segment is a great-circle arc shorter than maxSegmentDeg that does not cross the date line
+type segment struct {
+	a, b   vec3    // end points
+	n      vec3    // unit normal of the arc's great circle, or zero if the end points are the same
+	offset float64 // angle along the path from its first Point to the start of the segment
+	bounds         // bounding box of the whole arc
+}
+
+// newSegments splits a path into short segments
+func newSegments(path []Point) []segment {
+	var segments []segment
+	if len(path) == 1 {
+		v := toVec3(path[0].Lon(), path[0].Lat())
+		return append(segments, newSegment(v, v))
+	}
+	for p := 1; p < len(path); p++ {
+		a := toVec3(path[p-1].Lon(), path[p-1].Lat())
+		b := toVec3(path[p].Lon(), path[p].Lat())
+		if angle(a, b) <= math.Pi/2 {
+			segments = appendArc(segments, a, b)
+			continue
+		}
+		// interpolating arcs that are nearly half of a great circle is unstable, so split them in the middle first
+		m := midpoint(a, b)
+		segments = appendArc(appendArc(segments, a, m), m, b)
+	}
+
+	offset := 0.0
+	for i := range segments {
+		segments[i].offset = offset
+		offset += angle(segments[i].a, segments[i].b)
+	}
+	return segments
+}
+
+// appendArc splits an arc of at most a quarter of a great circle into pieces of equal length, and appends them
+func appendArc(segments []segment, a, b vec3) []segment {
+	pieces := int(math.Ceil(angle(a, b) / rad / maxSegmentDeg))
+	if pieces < 1 {
+		pieces = 1
+	}
+	prev := a
+	for i := 1; i <= pieces; i++ {
+		next := slerp(a, b, float64(i)/float64(pieces))
+		segments = append(segments, splitDateLine(prev, next)...)
+		prev = next
+	}
+	return segments
+}
+
+// midpoint gets the point halfway along the great-circle arc from a to b. Antipodal points are connected by every
+// great circle through them, so their midpoint is on an arbitrary (but deterministic) one.
+func midpoint(a, b vec3) vec3 {
+	if m := a.add(b); m.norm() > 1e-9 {
+		return m.normalize()
+	}
+	axis := vec3{z: 1}
+	if math.Abs(a.z) > 0.5 {
+		axis = vec3{x: 1}
+	}
+	return a.cross(axis).normalize()
+}
+
+// splitDateLine splits an arc where it crosses the date line, so each segment's bounding box is valid
+func splitDateLine(a, b vec3) []segment {
+	lonA, _ := a.lonLat()
+	lonB, _ := b.lonLat()
+	if math.Abs(lonA-lonB) <= 180 {
+		return []segment{newSegment(a, b)}
+	}
+	// the arc crosses the plane of the prime meridian and the date line on the date line side
+	c := a.cross(b).cross(vec3{y: 1}).normalize()
+	if c.x > 0 {
+		c = c.scale(-1)
+	}
+	first, second := newSegment(a, c), newSegment(c, b)
+	if lonA > 0 {
+		first.MinLon, first.MaxLon = lonA, 180
+		second.MinLon, second.MaxLon = -180, lonB
+	} else {
+		first.MinLon, first.MaxLon = -180, lonA
+		second.MinLon, second.MaxLon = lonB, 180
+	}
+	return []segment{first, second}
+}
+
+// newSegment creates a segment from a short arc
+func newSegment(a, b vec3) segment {
+	s := segment{a: a, b: b, n: a.cross(b).normalize()}
+	lonA, latA := a.lonLat()
+	lonB, latB := b.lonLat()
+	s.MinLon, s.MaxLon = math.Min(lonA, lonB), math.Max(lonA, lonB)
+	s.MinLat, s.MaxLat = math.Min(latA, latB), math.Max(latA, latB)
+
+	// the arc may bulge past its end points towards the poles
+	north := vec3{z: 1}
+	if vertex := north.sub(s.n.scale(s.n.z)).normalize(); vertex.norm() > 0 {
+		if s.contains(vertex) {
+			_, s.MaxLat = vertex.lonLat()
+		}
+		if s.contains(vertex.scale(-1)) {
+			_, s.MinLat = vertex.scale(-1).lonLat()
+		}
+	}
+	return s
+}
+
+// contains reports whether the projection of v onto the segment's great circle falls between its end points
+func (s segment) contains(v vec3) bool {
+	return s.a.cross(v).dot(s.n) >= 0 && v.cross(s.b).dot(s.n) >= 0
+}
+
+// angleTo gets the angle in radians between v and the closest point of the segment
+func (s segment) angleTo(v vec3) float64 {
+	if s.n.norm() > 0 && s.contains(v) {
+		// cross-track distance to the great circle
+		return math.Abs(math.Asin(math.Max(-1, math.Min(1, v.dot(s.n)))))
+	}
+	return math.Min(angle(v, s.a), angle(v, s.b))
+}
+
+// along gets the angle along the path from its first Point to the closest point of the segment to v
+func (s segment) along(v vec3) float64 {
+	length := angle(s.a, s.b)
+	if s.n.norm() > 0 && s.contains(v) {
+		// angle to the projection of v onto the great circle
+		return s.offset + math.Min(angle(s.a, v.sub(s.n.scale(v.dot(s.n)))), length)
+	}
+	if angle(v, s.a) <= angle(v, s.b) {
+		return s.offset
+	}
+	return s.offset + length
+}
+
+// pathDist gets the distance (haversine) from a location to the closest segment
+func pathDist(segments []segment, lon, lat float64) float64 {
+	_, dist := closestSegment(segments, lon, lat)
+	return dist
+}
+
+// closestSegment gets the index of the closest segment to a location and the distance (haversine) to it
+func closestSegment(segments []segment, lon, lat float64) (closest int, dist float64) {
+	v := toVec3(lon, lat)
+	pt := Coordinates{lon: lon, lat: lat}
+	cosLat := math.Cos(lat * rad)
+
+	dist = math.Inf(1)
+	for i, s := range segments {
+		// skip segments that cannot be closer than the closest one so far
+		if boxDist(pt, cosLat, s.bounds) >= dist {
+			continue
+		}
+		if d := haverSin(s.angleTo(v)); d < dist {
+			closest, dist = i, d
+		}
+	}
+	return closest, dist
+}
+
+// pathBoxDist gets the lower bound for distance from the segments to points inside a bounding box
+func pathBoxDist(segments []segment, b bounds) float64 {
+	best := math.Inf(1)
+	for _, s := range segments {
+		best = math.Min(best, boxBoxDist(s.bounds, b))
+	}
+	return best
+}
+
+// slerp interpolates along the great-circle arc from a to b
+func slerp(a, b vec3, t float64) vec3 {
+	theta := angle(a, b)
+	if theta == 0 {
+		return a
+	}
+	sinTheta := math.Sin(theta)
+	return a.scale(math.Sin((1-t)*theta) / sinTheta).add(b.scale(math.Sin(t*theta) / sinTheta))
+}
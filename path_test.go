@@ -0,0 +1,237 @@
+package neighborhood
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestKDTree_NearbyPath(t *testing.T) {
+	idx := NewIndex().Load(namedPoints()...).(*KDTree)
+
+	// a flight from seattle to memphis passes closer to memphis and seattle than anything else
+	path := []Point{NewCoordinates(-122.3, 47.4), NewCoordinates(-90, 35)}
+	results := idx.NearbyPath(path, 3, AcceptAny)
+	assertEqual(t, 3, len(results))
+	names := []string{results[0].(*NamedPoint).Name, results[1].(*NamedPoint).Name, results[2].(*NamedPoint).Name}
+	sort.Strings(names)
+	assertEqual(t, "memphis", names[0])
+	assertEqual(t, "seattle", names[1])
+	assertEqual(t, "woodinville", names[2])
+
+	results = idx.NearbyPath(path, 3, func(pt Point) bool {
+		return pt.(*NamedPoint).Name != "memphis"
+	})
+	assertEqual(t, 3, len(results))
+	assertEqual(t, "anchorage", results[2].(*NamedPoint).Name)
+}
+
+func TestKDTree_NearbyPath_CrossTrack(t *testing.T) {
+	pts := []Point{
+		&NamedPoint{Point: NewCoordinates(0, 0.5), Name: "beside-the-middle"},
+		&NamedPoint{Point: NewCoordinates(-11, 0), Name: "past-the-start"},
+	}
+	idx := NewIndex().Load(pts...).(*KDTree)
+
+	// the first point is far from both end points, but close to the middle of the segment
+	path := []Point{NewCoordinates(-10, 0), NewCoordinates(10, 0)}
+	results := idx.NearbyPath(path, 2, AcceptAny)
+	assertEqual(t, 2, len(results))
+	assertEqual(t, "beside-the-middle", results[0].(*NamedPoint).Name)
+	assertEqual(t, "past-the-start", results[1].(*NamedPoint).Name)
+}
+
+func TestKDTree_NearbyPath_AntiMeridian(t *testing.T) {
+	pts := []Point{
+		&NamedPoint{Point: NewCoordinates(180, 51), Name: "on-the-route"},
+		&NamedPoint{Point: NewCoordinates(0, 51), Name: "other-side"},
+	}
+	idx := NewIndex().Load(pts...).(*KDTree)
+
+	// a route across the pacific
+	path := []Point{NewCoordinates(170, 50), NewCoordinates(-170, 50)}
+	results := idx.NearbyPath(path, 1, AcceptAny)
+	assertEqual(t, 1, len(results))
+	assertEqual(t, "on-the-route", results[0].(*NamedPoint).Name)
+}
+
+func TestKDTree_NearbyPath_Random(t *testing.T) {
+	rnd := rand.New(rand.NewSource(7))
+	pts := make([]Point, 2_000)
+	for i := range pts {
+		pts[i] = NewCoordinates(rnd.Float64()*360-180, rnd.Float64()*180-90)
+	}
+	idx := NewKDTreeIndex(KDTreeOptions{NodeSize: 8}).Load(pts...).(*KDTree)
+
+	for trial := 0; trial < 20; trial++ {
+		path := make([]Point, 1+rnd.Intn(4))
+		for i := range path {
+			path[i] = NewCoordinates(rnd.Float64()*360-180, rnd.Float64()*160-80)
+		}
+		segments := newSegments(path)
+
+		// compare with the distances of all points
+		var expected []float64
+		for _, pt := range pts {
+			expected = append(expected, pathDist(segments, pt.Lon(), pt.Lat()))
+		}
+		sort.Float64s(expected)
+
+		results := idx.NearbyPath(path, 10, AcceptAny)
+		assertEqual(t, 10, len(results))
+		for i, result := range results {
+			assertEqual(t, expected[i], pathDist(segments, result.Lon(), result.Lat()))
+		}
+	}
+}
+
+func TestKDTree_NearbyPath_Empty(t *testing.T) {
+	idx := NewIndex().Load(namedPoints()...).(*KDTree)
+	assertEqual(t, 0, len(idx.NearbyPath(nil, 3, AcceptAny)))
+
+	// a single point path is a regular nearby search
+	results := idx.NearbyPath([]Point{NewCoordinates(-122, 47)}, 2, AcceptAny)
+	assertEqual(t, 2, len(results))
+	assertEqual(t, "seattle", results[0].(*NamedPoint).Name)
+	assertEqual(t, "woodinville", results[1].(*NamedPoint).Name)
+}
+
+func TestSegment_Bounds(t *testing.T) {
+	// a great circle between two points at 60N bulges towards the pole
+	segments := newSegments([]Point{NewCoordinates(-40, 60), NewCoordinates(40, 60)})
+	maxLat := -90.0
+	for _, s := range segments {
+		maxLat = math.Max(maxLat, s.MaxLat)
+	}
+	assertEqual(t, true, maxLat > 65)
+
+	// every interpolated point of the path is inside a segment's bounding box
+	a, b := toVec3(-40, 60), toVec3(40, 60)
+	for i := 0; i <= 100; i++ {
+		lon, lat := slerp(a, b, float64(i)/100).lonLat()
+		inside := false
+		for _, s := range segments {
+			if lon >= s.MinLon-1e-9 && lon <= s.MaxLon+1e-9 && lat >= s.MinLat-1e-9 && lat <= s.MaxLat+1e-9 {
+				inside = true
+			}
+		}
+		assertEqual(t, true, inside)
+	}
+}
+
+func TestKDTree_Path_Antipodal(t *testing.T) {
+	// consecutive antipodal (and nearly antipodal) Points make valid segments covering half of a great circle
+	for _, path := range [][]Point{
+		{NewCoordinates(0, 0), NewCoordinates(180, 0)},
+		{NewCoordinates(10, 20), NewCoordinates(-170, -20)},
+		{NewCoordinates(10, 20), NewCoordinates(-170, -20.0000001)},
+		{NewCoordinates(0, 90), NewCoordinates(0, -90)},
+	} {
+		segments := newSegments(path)
+		length := 0.0
+		for _, s := range segments {
+			for _, v := range []float64{s.a.x, s.a.y, s.a.z, s.b.x, s.b.y, s.b.z, s.MinLon, s.MinLat, s.MaxLon, s.MaxLat} {
+				assertEqual(t, false, math.IsNaN(v))
+			}
+			length += angle(s.a, s.b)
+		}
+		assertEqual(t, true, math.Abs(length-math.Pi) < 1e-6)
+	}
+
+	// the path between antipodal Points on the equator follows the equator
+	pts := []Point{
+		&NamedPoint{Point: NewCoordinates(-179.5, 0.5), Name: "last"},
+		&NamedPoint{Point: NewCoordinates(-90, 1), Name: "middle"},
+		&NamedPoint{Point: NewCoordinates(0.5, 0.5), Name: "first"},
+		&NamedPoint{Point: NewCoordinates(-45, 30), Name: "far"},
+	}
+	idx := NewKDTreeIndex(KDTreeOptions{NodeSize: 2}).Load(pts...).(*KDTree)
+	path := []Point{NewCoordinates(0, 0), NewCoordinates(180, 0)}
+	results := idx.Corridor(path, 200, AcceptAny)
+	assertEqual(t, 3, len(results))
+	assertEqual(t, "first", results[0].(*NamedPoint).Name)
+	assertEqual(t, "middle", results[1].(*NamedPoint).Name)
+	assertEqual(t, "last", results[2].(*NamedPoint).Name)
+
+	results = idx.NearbyPath(path, 4, AcceptAny)
+	assertEqual(t, "far", results[3].(*NamedPoint).Name)
+}
+
+func TestKDTree_Corridor(t *testing.T) {
+	pts := []Point{
+		&NamedPoint{Point: NewCoordinates(5, -0.2), Name: "second"},
+		&NamedPoint{Point: NewCoordinates(10.1, 3), Name: "third"},
+		&NamedPoint{Point: NewCoordinates(-1, 0.1), Name: "first"},
+		&NamedPoint{Point: NewCoordinates(5, 2), Name: "too-far"},
+	}
+	idx := NewKDTreeIndex(KDTreeOptions{NodeSize: 1}).Load(pts...).(*KDTree)
+
+	// east along the equator, then north
+	path := []Point{NewCoordinates(0, 0), NewCoordinates(10, 0), NewCoordinates(10, 10)}
+	results := idx.Corridor(path, 200, AcceptAny)
+	assertEqual(t, 3, len(results))
+	assertEqual(t, "first", results[0].(*NamedPoint).Name)
+	assertEqual(t, "second", results[1].(*NamedPoint).Name)
+	assertEqual(t, "third", results[2].(*NamedPoint).Name)
+
+	results = idx.Corridor(path, 200, func(pt Point) bool {
+		return pt.(*NamedPoint).Name != "second"
+	})
+	assertEqual(t, 2, len(results))
+	assertEqual(t, "first", results[0].(*NamedPoint).Name)
+	assertEqual(t, "third", results[1].(*NamedPoint).Name)
+
+	assertEqual(t, 0, len(idx.Corridor(nil, 200, AcceptAny)))
+}
+
+func TestKDTree_Corridor_AntiMeridian(t *testing.T) {
+	pts := []Point{
+		&NamedPoint{Point: NewCoordinates(-175, 50.5), Name: "east"},
+		&NamedPoint{Point: NewCoordinates(175, 50.5), Name: "west"},
+		&NamedPoint{Point: NewCoordinates(0, 50), Name: "other-side"},
+	}
+	idx := NewIndex().Load(pts...).(*KDTree)
+
+	// an undersea cable across the pacific, from west to east
+	path := []Point{NewCoordinates(170, 50), NewCoordinates(-170, 50)}
+	results := idx.Corridor(path, 100, AcceptAny)
+	assertEqual(t, 2, len(results))
+	assertEqual(t, "west", results[0].(*NamedPoint).Name)
+	assertEqual(t, "east", results[1].(*NamedPoint).Name)
+}
+
+func TestKDTree_Corridor_Random(t *testing.T) {
+	rnd := rand.New(rand.NewSource(11))
+	pts := make([]Point, 5_000)
+	for i := range pts {
+		pts[i] = NewCoordinates(rnd.Float64()*360-180, rnd.Float64()*180-90)
+	}
+	idx := NewKDTreeIndex(KDTreeOptions{NodeSize: 8}).Load(pts...).(*KDTree)
+
+	for trial := 0; trial < 10; trial++ {
+		path := make([]Point, 2+rnd.Intn(3))
+		for i := range path {
+			path[i] = NewCoordinates(rnd.Float64()*360-180, rnd.Float64()*160-80)
+		}
+		segments := newSegments(path)
+		maxDist := kmToHaverSin(300)
+
+		expected := 0
+		for _, pt := range pts {
+			if pathDist(segments, pt.Lon(), pt.Lat()) <= maxDist {
+				expected++
+			}
+		}
+		results := idx.Corridor(path, 300, AcceptAny)
+		assertEqual(t, expected, len(results))
+
+		// results are ordered along the path
+		for i := 1; i < len(results); i++ {
+			prev, _ := closestSegment(segments, results[i-1].Lon(), results[i-1].Lat())
+			next, _ := closestSegment(segments, results[i].Lon(), results[i].Lat())
+			assertEqual(t, true, segments[prev].along(toVec3(results[i-1].Lon(), results[i-1].Lat())) <=
+				segments[next].along(toVec3(results[i].Lon(), results[i].Lat())))
+		}
+	}
+}
@@ -1,12 +1,23 @@
 package neighborhood
 
-import "container/heap"
+import (
+	"container/heap"
+	"math"
+)
 
+// Node distances are lower bounds computed with rounding errors, so they are loosened by a tiny amount (see loosen)
+const (
+	nodeDistAbsSlack = 1e-14 // covers haversine values, which are at most 1
+	nodeDistRelSlack = 1e-12 // covers larger distances, like slant ranges in kilometers or scores
+)
+
+// item is either a Point or a node of an index (like a *kdTreeNode) that holds Points
 type item struct {
 	point    Point
-	node     *kdTreeNode
+	node     interface{}
 	distance float64
 	rank     float64
+	seq      int // insertion order of the point in the Index
 }
 
 // priorityQueue implements heap.Interface and holds searchPoints
@@ -20,29 +31,38 @@ func newPriorityQueue(capacity int) priorityQueue {
 }
 
 // PushPoint creates a new Point item and pushes it into the queue
-func (pq *priorityQueue) PushPoint(point Point, dist float64) {
-	// see if point implements optional Ranker interface
-	rank := 0.0
-	if ranked, ok := point.(Ranker); ok {
-		rank = ranked.GetRank()
-	}
+func (pq *priorityQueue) PushPoint(point Point, dist float64, seq int) {
 	heap.Push(pq, &item{
 		point:    point,
 		distance: dist,
-		rank:     rank,
+		rank:     rankOf(point), // see if point implements optional Ranker interface
+		seq:      seq,
 	})
 }
 
-// PushPoint creates a new searchPoint and pushes it into the queue
+// PushNode creates a new kd-tree node item and pushes it into the queue
 func (pq *priorityQueue) PushNode(node *kdTreeNode) {
-	// see if point implements optional Ranker interface
+	pq.PushNodeDist(node, node.Dist)
+}
+
+// PushNodeDist creates a new item for a node of any index and pushes it into the queue
+func (pq *priorityQueue) PushNodeDist(node interface{}, dist float64) {
 	heap.Push(pq, &item{
 		node:     node,
-		distance: node.Dist,
+		distance: loosen(dist),
 		rank:     -1.0,
 	})
 }
 
+// loosen lowers a node's distance a little, so that rounding errors never pop a node after a point that is at the
+// same distance as a point inside the node
+func loosen(dist float64) float64 {
+	if math.IsInf(dist, 0) {
+		return dist
+	}
+	return dist - nodeDistAbsSlack - nodeDistRelSlack*math.Abs(dist)
+}
+
 func (pq *priorityQueue) PopItem() *item {
 	if i := heap.Pop(pq); i != nil {
 		return i.(*item)
@@ -63,13 +83,25 @@ func (pq *priorityQueue) Peek() (itm *item) {
 //
 
 func (pq priorityQueue) Less(i, j int) bool {
-	// check for equal distances
-	if pq[i].distance == pq[j].distance {
+	return pq[i].before(pq[j])
+}
+
+// before reports whether an item should be popped before another item
+func (itm *item) before(other *item) bool {
+	if itm.distance != other.distance {
+		// Pop the lowest distance
+		return itm.distance < other.distance
+	}
+	// Pop nodes before points at equal distances, so that all tied points are queued before any of them is popped
+	if (itm.node != nil) != (other.node != nil) {
+		return itm.node != nil
+	}
+	if itm.rank != other.rank {
 		// Pop the highest rank (tie breaker)
-		return pq[i].rank > pq[j].rank
+		return itm.rank > other.rank
 	}
-	// Pop the lowest distance
-	return pq[i].distance < pq[j].distance
+	// Pop the earliest inserted point (final tie breaker), so results never depend on the kd-tree layout
+	return itm.seq < other.seq
 }
 
 func (pq priorityQueue) Len() int { return len(pq) }
@@ -12,9 +12,9 @@ func TestPriorityQueue_Peek(t *testing.T) {
 	memphis := namedPoint("memphis")
 	woodinville := namedPoint("woodinville")
 
-	q.PushPoint(woodinville, 234)
-	q.PushPoint(seattle, 123)
-	q.PushPoint(memphis, 2000)
+	q.PushPoint(woodinville, 234, 0)
+	q.PushPoint(seattle, 123, 1)
+	q.PushPoint(memphis, 2000, 2)
 
 	assertEqual(t, 3, q.Len())
 	peeked := q.Peek().point
@@ -30,3 +30,22 @@ func TestPriorityQueue_Empty(t *testing.T) {
 	assertNil(t, q.Peek())
 	assertNil(t, q.PopItem())
 }
+
+func TestPriorityQueue_TieBreakers(t *testing.T) {
+	q := newPriorityQueue(10)
+
+	low := &RankedPoint{Point: points["seattle"], Name: "low", Rank: 1}
+	high := &RankedPoint{Point: points["seattle"], Name: "high", Rank: 5}
+	late := &RankedPoint{Point: points["seattle"], Name: "late", Rank: 5}
+
+	q.PushPoint(late, 100, 7)
+	q.PushPoint(low, 100, 1)
+	q.PushPoint(high, 100, 3)
+	q.PushNode(&kdTreeNode{Dist: 100})
+
+	// nodes first, then the highest rank, then the earliest inserted
+	assertEqual(t, true, q.PopItem().node != nil)
+	assertEqual(t, "high", q.PopItem().point.(*RankedPoint).Name)
+	assertEqual(t, "late", q.PopItem().point.(*RankedPoint).Name)
+	assertEqual(t, "low", q.PopItem().point.(*RankedPoint).Name)
+}
@@ -0,0 +1,1001 @@
+package neighborhood
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// property checks a query against an oracle for a set of Points, and describes the failure if there is one
+type property func(pts []Point) string
+
+// TestProperties compares the queries with brute-force oracles on random Points, including Points near the poles,
+// on the date line, duplicates and clusters. Oracles measure distances with their own vector math (see oracleKm),
+// so they also check the distance math of the queries, not only their pruning.
+func TestProperties(t *testing.T) {
+	for seed := int64(1); seed <= 50; seed++ {
+		rnd := rand.New(rand.NewSource(seed))
+		pts := randomTestPoints(rnd, 1+rnd.Intn(400))
+		origin := randomTestPoint(rnd, pts)
+		k := 1 + rnd.Intn(40)
+		radiusKm := math.Pow(10, rnd.Float64()*4.3)
+		tieKm := []float64{0, 1, 100, 2000}[rnd.Intn(4)]
+		b := randomTestBox(rnd)
+		path := randomTestPath(rnd, pts)
+		accept := randomTestAccepter(rnd)
+
+		checkProperty(t, seed, pts, nearbyProperty(origin, k, accept))
+		checkProperty(t, seed, pts, farthestProperty(origin, k, accept))
+		checkProperty(t, seed, pts, nearbyPathProperty(path, k, accept))
+		checkProperty(t, seed, pts, withinProperty(origin, radiusKm, accept))
+		checkProperty(t, seed, pts, corridorProperty(path, radiusKm/10, accept))
+		checkProperty(t, seed, pts, rangeProperty(b, accept))
+		checkProperty(t, seed, pts, closestPairsProperty(k, accept))
+		checkProperty(t, seed, pts, indexesProperty(origin, k, accept))
+		checkProperty(t, seed, pts, tieEpsilonProperty(origin, k, tieKm, accept))
+		checkProperty(t, seed, pts, altitudeProperty(origin, k, tieKm, accept))
+		checkProperty(t, seed, pts, scoredProperty(origin, k, RankPenalty(rnd.Float64()*1000), accept))
+		checkProperty(t, seed, pts, matchingProperty(origin, k, Match{"parity": {"0"}, "digits": {"1", "3"}}, accept))
+		checkProperty(t, seed, pts, diverseProperty(origin, k, radiusKm/10, rnd.Intn(4), accept))
+		checkProperty(t, seed, pts, groupedProperty(origin, k, tieKm, accept))
+		checkProperty(t, seed, pts, temporalProperty(origin, k, tieKm, rnd.Intn(6), rnd.Intn(6), accept))
+		checkProperty(t, seed, pts, shardedTiesProperty(origin, k, tieKm, accept))
+		checkProperty(t, seed, pts, intersectingProperty(b, accept))
+		checkProperty(t, seed, pts, inCellsProperty(origin, radiusKm, b, accept))
+		checkProperty(t, seed, pts, densityProperty(origin, radiusKm/10))
+		checkProperty(t, seed, pts, dbscanProperty(radiusKm/10, 1+rnd.Intn(5)))
+		checkProperty(t, seed, pts, duplicatesProperty(radiusKm/100))
+	}
+}
+
+func TestMinimize(t *testing.T) {
+	pts := make([]Point, 100)
+	for i := range pts {
+		pts[i] = &RankedPoint{Point: NewCoordinates(float64(i), 0)}
+	}
+	// fails whenever both Points 17 and 60 are present
+	minimal := minimize(pts, func(pts []Point) string {
+		found := 0
+		for _, pt := range pts {
+			if pt.Lon() == 17 || pt.Lon() == 60 {
+				found++
+			}
+		}
+		if found == 2 {
+			return "failed"
+		}
+		return ""
+	})
+	assertEqual(t, 2, len(minimal))
+	assertEqual(t, 17.0, minimal[0].Lon())
+	assertEqual(t, 60.0, minimal[1].Lon())
+}
+
+// checkProperty fails the test with a minimal set of Points for which a property does not hold
+func checkProperty(t *testing.T, seed int64, pts []Point, prop property) {
+	t.Helper()
+	failure := prop(pts)
+	if failure == "" {
+		return
+	}
+	minimal := minimize(pts, prop)
+	t.Fatalf("seed %d: %s\nminimized from %d to %d Points: %s", seed, prop(minimal), len(pts), len(minimal),
+		formatTestPoints(minimal))
+}
+
+// minimize removes Points from a failing set of Points for as long as the property still fails, first in large
+// chunks and then one at a time
+func minimize(pts []Point, prop property) []Point {
+	for chunk := len(pts) / 2; chunk >= 1; chunk /= 2 {
+		for start := 0; start < len(pts); {
+			end := int(math.Min(float64(start+chunk), float64(len(pts))))
+			smaller := append(append([]Point{}, pts[:start]...), pts[end:]...)
+			if prop(smaller) != "" {
+				pts = smaller
+			} else {
+				start = end
+			}
+		}
+	}
+	return pts
+}
+
+// formatTestPoints formats Points as Go code to reproduce a failure
+func formatTestPoints(pts []Point) string {
+	var b strings.Builder
+	b.WriteString("[]Point{\n")
+	for _, pt := range pts {
+		rp := pt.(*RankedPoint)
+		fmt.Fprintf(&b, "\t&RankedPoint{Point: NewCoordinates(%v, %v), Name: %q, Rank: %v},\n", pt.Lon(), pt.Lat(), rp.Name,
+			rp.Rank)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+func nearbyProperty(origin Point, k int, accept Accepter) property {
+	return func(pts []Point) string {
+		expected := NewBruteForceIndex().Load(pts...).Nearby(origin, k, accept)
+		actual := NewKDTreeIndex(KDTreeOptions{NodeSize: 4}).Load(pts...).Nearby(origin, k, accept)
+		return compareOrdered(fmt.Sprintf("Nearby(%v, %d)", origin, k), expected, actual)
+	}
+}
+
+func farthestProperty(origin Point, k int, accept Accepter) property {
+	return func(pts []Point) string {
+		cosLat := math.Cos(origin.Lat() * rad)
+		expected := bruteForceOrder(pts, k, accept, func(pt Point) float64 {
+			return 1 - haverSinDist(origin, pt.Lon(), pt.Lat(), cosLat)
+		})
+		actual := NewKDTreeIndex(KDTreeOptions{NodeSize: 4}).Load(pts...).(*KDTree).Farthest(origin, k, accept)
+		return compareOrdered(fmt.Sprintf("Farthest(%v, %d)", origin, k), expected, actual)
+	}
+}
+
+func nearbyPathProperty(path []Point, k int, accept Accepter) property {
+	return func(pts []Point) string {
+		dist := func(pt Point) float64 {
+			km, _ := oraclePath(path, pt)
+			return km
+		}
+		expected := oracleOrder(pts, k, accept, dist, 0)
+		actual := NewKDTreeIndex(KDTreeOptions{NodeSize: 4}).Load(pts...).(*KDTree).NearbyPath(path, k, accept)
+		return compareRanked(fmt.Sprintf("NearbyPath(%v, %d)", path, k), expected, actual)
+	}
+}
+
+func withinProperty(origin Point, radiusKm float64, accept Accepter) property {
+	return func(pts []Point) string {
+		maxDist := kmToHaverSin(radiusKm)
+		cosLat := math.Cos(origin.Lat() * rad)
+		inside := func(pt Point) bool { return haverSinDist(origin, pt.Lon(), pt.Lat(), cosLat) <= maxDist }
+		expected, count := bruteForceFilter(pts, accept, inside)
+
+		idx := NewKDTreeIndex(KDTreeOptions{NodeSize: 4}).Load(pts...).(*KDTree)
+		query := fmt.Sprintf("Within(%v, %v)", origin, radiusKm)
+		if failure := compareUnordered(query, expected, idx.Within(origin, radiusKm, accept)); failure != "" {
+			return failure
+		}
+		if actual := idx.CountWithin(origin, radiusKm); actual != count {
+			return fmt.Sprintf("CountWithin(%v, %v) = %d, expected %d", origin, radiusKm, actual, count)
+		}
+		return ""
+	}
+}
+
+func corridorProperty(path []Point, widthKm float64, accept Accepter) property {
+	return func(pts []Point) string {
+		// Points within a tiny margin of the corridor's edge may be found or not, depending on rounding
+		var expected []Point
+		for _, pt := range pts {
+			if km, _ := oraclePath(path, pt); accept(pt) && km <= widthKm && math.Abs(km-widthKm) > 1e-6 {
+				expected = append(expected, pt)
+			}
+		}
+		var actual []Point
+		for _, pt := range NewKDTreeIndex(KDTreeOptions{NodeSize: 4}).Load(pts...).(*KDTree).Corridor(path, widthKm, accept) {
+			if km, _ := oraclePath(path, pt); math.Abs(km-widthKm) > 1e-6 {
+				actual = append(actual, pt)
+			}
+		}
+		query := fmt.Sprintf("Corridor(%v, %v)", path, widthKm)
+		if failure := compareUnordered(query, expected, actual); failure != "" {
+			return failure
+		}
+
+		// Points are ordered by how far along the path they are
+		for i := 1; i < len(actual); i++ {
+			_, prev := oraclePath(path, actual[i-1])
+			_, along := oraclePath(path, actual[i])
+			if along < prev-1e-6 {
+				return fmt.Sprintf("%s result %d is %v km along the path, before result %d at %v km", query, i, along,
+					i-1, prev)
+			}
+		}
+		return ""
+	}
+}
+
+func rangeProperty(b bounds, accept Accepter) property {
+	return func(pts []Point) string {
+		inside := func(pt Point) bool {
+			inLon := pt.Lon() >= b.MinLon && pt.Lon() <= b.MaxLon
+			if b.MinLon > b.MaxLon {
+				inLon = pt.Lon() >= b.MinLon || pt.Lon() <= b.MaxLon
+			}
+			return inLon && pt.Lat() >= b.MinLat && pt.Lat() <= b.MaxLat
+		}
+		expected, count := bruteForceFilter(pts, accept, inside)
+
+		idx := NewKDTreeIndex(KDTreeOptions{NodeSize: 4}).Load(pts...).(*KDTree)
+		query := fmt.Sprintf("Range(%v, %v, %v, %v)", b.MinLon, b.MinLat, b.MaxLon, b.MaxLat)
+		if failure := compareUnordered(query, expected, idx.Range(b.MinLon, b.MinLat, b.MaxLon, b.MaxLat, accept)); failure != "" {
+			return failure
+		}
+		if actual := idx.CountRange(b.MinLon, b.MinLat, b.MaxLon, b.MaxLat); actual != count {
+			return fmt.Sprintf("Count%s = %d, expected %d", query, actual, count)
+		}
+		return ""
+	}
+}
+
+func closestPairsProperty(k int, accept Accepter) property {
+	return func(pts []Point) string {
+		var expected []float64
+		for i, a := range pts {
+			for _, b := range pts[i+1:] {
+				if accept(a) && accept(b) {
+					expected = append(expected, oracleKm(a, b))
+				}
+			}
+		}
+		sort.Float64s(expected)
+		if len(expected) > k {
+			expected = expected[:k]
+		}
+
+		// pairs at nearly the same distance may be in either order, so only compare distances
+		pairs := NewKDTreeIndex(KDTreeOptions{NodeSize: 4}).Load(pts...).(*KDTree).ClosestPairs(k, accept)
+		if len(pairs) != len(expected) {
+			return fmt.Sprintf("ClosestPairs(%d) found %d pairs, expected %d", k, len(pairs), len(expected))
+		}
+		seq := loadOrder(pts)
+		for i, pair := range pairs {
+			if math.Abs(pair.DistanceKm-expected[i]) > 1e-6 || seq[pair.A] >= seq[pair.B] {
+				return fmt.Sprintf("ClosestPairs(%d) pair %d is %v and %v, %v km apart, expected %v km", k, i, pair.A,
+					pair.B, pair.DistanceKm, expected[i])
+			}
+		}
+		return ""
+	}
+}
+
+// indexesProperty checks that every Index implementation finds the same nearest Points
+func indexesProperty(origin Point, k int, accept Accepter) property {
+	return func(pts []Point) string {
+		expected := NewBruteForceIndex().Load(pts...).Nearby(origin, k, accept)
+		indexes := map[string]Index{
+			"GeohashGrid": NewGeohashGridIndex(GeohashGridOptions{Precision: 3}),
+			"CellIndex":   NewCellIndex(CellIndexOptions{NodeSize: 4}),
+			"RTree":       NewRTreeIndex(RTreeOptions{NodeSize: 4}),
+			"ECEFTree":    NewECEFTreeIndex(ECEFTreeOptions{NodeSize: 4}),
+			"Sharded":     NewShardedIndex(ShardedOptions{Shards: 7, KDTree: KDTreeOptions{NodeSize: 4}}),
+		}
+		for name, idx := range indexes {
+			actual := idx.Load(pts...).Nearby(origin, k, accept)
+			query := fmt.Sprintf("%s.Nearby(%v, %d)", name, origin, k)
+			if name == "ECEFTree" {
+				// chord distances may round differently, so nearly tied Points may be in a different order
+				if failure := compareDistances(query, origin, expected, actual); failure != "" {
+					return failure
+				}
+			} else if failure := compareOrdered(query, expected, actual); failure != "" {
+				return failure
+			}
+		}
+		return ""
+	}
+}
+
+func tieEpsilonProperty(origin Point, k int, tieKm float64, accept Accepter) property {
+	return func(pts []Point) string {
+		dist := func(pt Point) float64 { return oracleKm(origin, pt) }
+		expected := oracleOrder(pts, k, accept, dist, tieKm)
+		actual := NewKDTreeIndex(KDTreeOptions{NodeSize: 4, TieEpsilonKm: tieKm}).Load(pts...).Nearby(origin, k, accept)
+		return compareRanked(fmt.Sprintf("Nearby(%v, %d) with TieEpsilonKm %v", origin, k, tieKm), expected, actual)
+	}
+}
+
+func altitudeProperty(origin Point, k int, tieKm float64, accept Accepter) property {
+	return func(pts []Point) string {
+		above := &AltitudePoint{Point: origin, Meters: 10_000}
+		lifted := make([]Point, len(pts))
+		for i, pt := range pts {
+			lifted[i] = &AltitudePoint{Point: pt, Meters: float64(testPointID(pt)*7919%9000) - 500}
+		}
+		liftedAccept := func(pt Point) bool { return accept(pt.(*AltitudePoint).Point) }
+		dist := func(pt Point) float64 { return oracleSlantKm(above, pt) }
+
+		expected := oracleOrder(lifted, k, liftedAccept, dist, tieKm)
+		opts := KDTreeOptions{NodeSize: 4, TieEpsilonKm: tieKm, Altitude: true}
+		actual := NewKDTreeIndex(opts).Load(lifted...).Nearby(above, k, liftedAccept)
+		return compareRanked(fmt.Sprintf("altitude Nearby(%v, %d)", origin, k), expected, actual)
+	}
+}
+
+func scoredProperty(origin Point, k int, score Scorer, accept Accepter) property {
+	return func(pts []Point) string {
+		key := func(pt Point) float64 { return score(oracleKm(origin, pt), rankOf(pt)) }
+		expected := oracleOrder(pts, k, accept, key, 0)
+		actual := NewKDTreeIndex(KDTreeOptions{NodeSize: 4}).Load(pts...).(*KDTree).NearbyScored(origin, k, accept, score)
+		return compareRanked(fmt.Sprintf("NearbyScored(%v, %d)", origin, k), expected, actual)
+	}
+}
+
+func matchingProperty(origin Point, k int, match Match, accept Accepter) property {
+	return func(pts []Point) string {
+		attrs := testPointAttributes()
+		matches := func(pt Point) bool {
+			for _, attr := range attrs {
+				found := false
+				for _, value := range match[attr.Name] {
+					found = found || attr.Value(pt) == value
+				}
+				if !found {
+					return false
+				}
+			}
+			return accept(pt)
+		}
+		dist := func(pt Point) float64 { return oracleKm(origin, pt) }
+		expected := oracleOrder(pts, k, matches, dist, 0)
+		opts := KDTreeOptions{NodeSize: 4, Attributes: attrs}
+		actual := NewKDTreeIndex(opts).Load(pts...).(*KDTree).NearbyMatching(origin, k, match, accept)
+		return compareRanked(fmt.Sprintf("NearbyMatching(%v, %d, %v)", origin, k, match), expected, actual)
+	}
+}
+
+func diverseProperty(origin Point, k int, minSeparationKm float64, maxPerKey int, accept Accepter) property {
+	return func(pts []Point) string {
+		opts := DiversityOptions{
+			MinSeparationKm: minSeparationKm,
+			Key:             func(p Point) string { return fmt.Sprint(rankOf(p)) },
+			MaxPerKey:       maxPerKey,
+		}
+
+		// greedily select the candidates in the order of Nearby
+		candidates := oracleOrder(pts, len(pts), accept, func(pt Point) float64 { return oracleKm(origin, pt) }, 0)
+		var expected []Point
+		perKey := make(map[string]int)
+		for _, pt := range candidates.points {
+			if len(expected) == k {
+				break
+			}
+			diverse := maxPerKey <= 0 || perKey[opts.Key(pt)] < maxPerKey
+			for _, selected := range expected {
+				diverse = diverse && oracleKm(pt, selected) >= minSeparationKm
+			}
+			if diverse {
+				expected = append(expected, pt)
+				perKey[opts.Key(pt)]++
+			}
+		}
+
+		actual := NewKDTreeIndex(KDTreeOptions{NodeSize: 4}).Load(pts...).(*KDTree).NearbyDiverse(origin, k, accept, opts)
+		query := fmt.Sprintf("NearbyDiverse(%v, %d, %v, %d)", origin, k, minSeparationKm, maxPerKey)
+		if !candidates.ambiguous {
+			return compareOrdered(query, expected, actual)
+		}
+
+		// nearly tied candidates may be selected in either order, so only check the constraints
+		perKey = make(map[string]int)
+		for i, pt := range actual {
+			for _, selected := range actual[:i] {
+				if oracleKm(pt, selected) < minSeparationKm-1e-6 {
+					return fmt.Sprintf("%s selected %v and %v, %v km apart", query, selected, pt, oracleKm(pt, selected))
+				}
+			}
+			if perKey[opts.Key(pt)]++; maxPerKey > 0 && perKey[opts.Key(pt)] > maxPerKey {
+				return fmt.Sprintf("%s selected more than %d Points with key %s", query, maxPerKey, opts.Key(pt))
+			}
+		}
+		return ""
+	}
+}
+
+func groupedProperty(origin Point, k int, tieKm float64, accept Accepter) property {
+	return func(pts []Point) string {
+		quadrant := func(p Point) string { return fmt.Sprint(p.Lon() < 0, p.Lat() < 0) }
+		dist := func(pt Point) float64 { return oracleKm(origin, pt) }
+		idx := NewKDTreeIndex(KDTreeOptions{NodeSize: 4, TieEpsilonKm: tieKm}).Load(pts...).(*KDTree)
+		actual := idx.NearbyGrouped(origin, k, accept, quadrant)
+
+		groups := make(map[string]bool)
+		for _, pt := range pts {
+			if accept(pt) {
+				groups[quadrant(pt)] = true
+			}
+		}
+		if len(actual) != len(groups) {
+			return fmt.Sprintf("NearbyGrouped(%v, %d) found %d groups, expected %d", origin, k, len(actual), len(groups))
+		}
+		for g := range groups {
+			expected := oracleOrder(pts, k, func(pt Point) bool { return quadrant(pt) == g && accept(pt) }, dist, tieKm)
+			query := fmt.Sprintf("NearbyGrouped(%v, %d) group %s with TieEpsilonKm %v", origin, k, g, tieKm)
+			if failure := compareRanked(query, expected, actual[g]); failure != "" {
+				return failure
+			}
+		}
+		return ""
+	}
+}
+
+func temporalProperty(origin Point, k int, tieKm float64, fromHour, toHour int, accept Accepter) property {
+	return func(pts []Point) string {
+		stamped := make([]Point, len(pts))
+		for i, pt := range pts {
+			stamped[i] = &rankedTimestampedPoint{
+				RankedPoint: pt.(*RankedPoint),
+				Time:        temporalStart.Add(time.Duration(testPointID(pt)*37%300) * time.Minute),
+			}
+		}
+		var from, to time.Time
+		if fromHour > 0 {
+			from = temporalStart.Add(time.Duration(fromHour)*time.Hour - 20*time.Minute)
+		}
+		if toHour > 0 {
+			to = temporalStart.Add(time.Duration(toHour)*time.Hour + 10*time.Minute)
+		}
+		stampedAccept := func(pt Point) bool { return accept(pt.(*rankedTimestampedPoint).RankedPoint) }
+		inRange := func(pt Point) bool {
+			ts := pt.(*rankedTimestampedPoint).Time
+			return (from.IsZero() || !ts.Before(from)) && (to.IsZero() || ts.Before(to)) && stampedAccept(pt)
+		}
+		dist := func(pt Point) float64 { return oracleKm(origin, pt) }
+		expected := oracleOrder(stamped, k, inRange, dist, tieKm)
+
+		// Points added later are still in load order
+		opts := TemporalOptions{BucketDuration: time.Hour, KDTree: KDTreeOptions{NodeSize: 4, TieEpsilonKm: tieKm}}
+		ti := NewTemporalIndex(opts)
+		ti.Load(stamped[:len(stamped)/2]...)
+		ti.Add(stamped[len(stamped)/2:]...)
+		actual := ti.NearbyBetween(origin, k, from, to, stampedAccept)
+		query := fmt.Sprintf("NearbyBetween(%v, %d, %v, %v) with TieEpsilonKm %v", origin, k, from, to, tieKm)
+		return compareRanked(query, expected, actual)
+	}
+}
+
+func shardedTiesProperty(origin Point, k int, tieKm float64, accept Accepter) property {
+	return func(pts []Point) string {
+		dist := func(pt Point) float64 { return oracleKm(origin, pt) }
+		expected := oracleOrder(pts, k, accept, dist, tieKm)
+		si := NewShardedIndex(ShardedOptions{Shards: 7, KDTree: KDTreeOptions{NodeSize: 4, TieEpsilonKm: tieKm}})
+		si.Load(pts[:len(pts)/2]...)
+		si.Add(pts[len(pts)/2:]...)
+		actual := si.Nearby(origin, k, accept)
+		return compareRanked(fmt.Sprintf("ShardedIndex.Nearby(%v, %d) with TieEpsilonKm %v", origin, k, tieKm),
+			expected, actual)
+	}
+}
+
+func intersectingProperty(b bounds, accept Accepter) property {
+	return func(pts []Point) string {
+		// areas of up to 10 degrees around the Points, which may cross the date line
+		areas := make([]Point, len(pts))
+		areaAccept := func(pt Point) bool { return accept(pt.(*AreaPoint).Point) }
+		for i, pt := range pts {
+			size := float64(testPointID(pt)%11) * 0.9
+			minLat, maxLat := math.Max(pt.Lat()-size/2, -90), math.Min(pt.Lat()+size/2, 90)
+			areas[i] = &AreaPoint{Point: pt, Box: bounds{
+				MinLon: wrapLon(pt.Lon() - size), MinLat: minLat, MaxLon: wrapLon(pt.Lon() + size), MaxLat: maxLat,
+			}}
+		}
+		expected, _ := bruteForceFilter(areas, areaAccept, func(pt Point) bool {
+			return boxesIntersect(b, pt.(*AreaPoint).Box)
+		})
+		actual := NewRTreeIndex(RTreeOptions{NodeSize: 4}).Load(areas...).(*RTree).Intersecting(b.MinLon, b.MinLat,
+			b.MaxLon, b.MaxLat, areaAccept)
+		return compareUnordered(fmt.Sprintf("Intersecting(%+v)", b), expected, actual)
+	}
+}
+
+func inCellsProperty(origin Point, radiusKm float64, b bounds, accept Accepter) property {
+	return func(pts []Point) string {
+		coverer := CellCoverer{MinLevel: 0, MaxLevel: 20, MaxCells: 8}
+		idx := NewCellIndex(CellIndexOptions{NodeSize: 4}).Load(pts...).(*CellIndex)
+		for _, region := range []struct {
+			name   string
+			cells  []CellID
+			inside func(pt Point) bool
+		}{
+			{fmt.Sprintf("CoverCircle(%v, %v)", origin, radiusKm), coverer.CoverCircle(origin, radiusKm),
+				func(pt Point) bool { return oracleKm(origin, pt) < radiusKm-1e-6 }},
+			{fmt.Sprintf("CoverRect(%+v)", b), coverer.CoverRect(b.MinLon, b.MinLat, b.MaxLon, b.MaxLat),
+				func(pt Point) bool { return oracleInBox(b, pt) }},
+		} {
+			// the covering contains every location inside the region
+			covered := func(pt Point) bool {
+				leaf := CellIDFromPoint(pt)
+				for _, c := range region.cells {
+					if leaf.Parent(c.Level()) == c {
+						return true
+					}
+				}
+				return false
+			}
+			for _, pt := range pts {
+				if region.inside(pt) && !covered(pt) {
+					return fmt.Sprintf("%s does not cover %v", region.name, pt)
+				}
+			}
+
+			expected, _ := bruteForceFilter(pts, accept, covered)
+			if failure := compareUnordered("InCells("+region.name+")", expected, idx.InCells(region.cells, accept)); failure != "" {
+				return failure
+			}
+		}
+		return ""
+	}
+}
+
+func densityProperty(at Point, bandwidthKm float64) property {
+	return func(pts []Point) string {
+		idx := NewKDTreeIndex(KDTreeOptions{NodeSize: 4}).Load(pts...).(*KDTree)
+		for _, kernel := range []Kernel{GaussianKernel, EpanechnikovKernel} {
+			expected := 0.0
+			for _, pt := range pts {
+				u := oracleKm(at, pt) / bandwidthKm
+				if kernel == EpanechnikovKernel && u < 1 {
+					expected += 2 / math.Pi * (1 - u*u)
+				} else if kernel == GaussianKernel && u <= gaussianSupport {
+					expected += math.Exp(-u*u/2) / (2 * math.Pi)
+				}
+			}
+			expected /= bandwidthKm * bandwidthKm
+
+			actual := idx.Density(at, DensityOptions{Kernel: kernel, BandwidthKm: bandwidthKm})
+			if math.Abs(actual-expected) > 1e-7*expected {
+				return fmt.Sprintf("Density(%v, %v) with kernel %d = %v, expected %v", at, bandwidthKm, kernel, actual,
+					expected)
+			}
+		}
+		return ""
+	}
+}
+
+func dbscanProperty(epsKm float64, minPts int) property {
+	return func(pts []Point) string {
+		query := fmt.Sprintf("DBSCAN(%v, %d)", epsKm, minPts)
+		neighbors := make([][]int, len(pts))
+		core := make([]bool, len(pts))
+		for i := range pts {
+			for j := range pts {
+				if oracleKm(pts[i], pts[j]) <= epsKm {
+					neighbors[i] = append(neighbors[i], j)
+				}
+			}
+			core[i] = len(neighbors[i]) >= minPts
+		}
+
+		labels, noise := DBSCAN(pts, epsKm, minPts)
+		var expectedNoise []Point
+		for i := range pts {
+			// core Points are in the same cluster as their core neighbors, and border Points in a cluster of one of
+			// their core neighbors
+			reachable := false
+			for _, j := range neighbors[i] {
+				if core[j] {
+					reachable = true
+					if core[i] && labels[i] != labels[j] {
+						return fmt.Sprintf("%s put neighboring core Points %v and %v in clusters %d and %d", query, pts[i],
+							pts[j], labels[i], labels[j])
+					}
+				}
+			}
+			if !reachable {
+				expectedNoise = append(expectedNoise, pts[i])
+				if labels[i] != Noise {
+					return fmt.Sprintf("%s put %v in cluster %d, expected Noise", query, pts[i], labels[i])
+				}
+				continue
+			}
+			if labels[i] < 0 {
+				return fmt.Sprintf("%s put %v in cluster %d, expected a cluster", query, pts[i], labels[i])
+			}
+			if !core[i] {
+				found := false
+				for _, j := range neighbors[i] {
+					found = found || core[j] && labels[j] == labels[i]
+				}
+				if !found {
+					return fmt.Sprintf("%s put border Point %v in cluster %d without a core neighbor", query, pts[i],
+						labels[i])
+				}
+			}
+		}
+
+		// core Points that are not connected are in different clusters
+		first := make(map[int]int)
+		for i := range pts {
+			if !core[i] {
+				continue
+			}
+			if j, ok := first[labels[i]]; ok && !oracleConnected(neighbors, core, i, j) {
+				return fmt.Sprintf("%s put unconnected core Points %v and %v in cluster %d", query, pts[i], pts[j],
+					labels[i])
+			} else if !ok {
+				first[labels[i]] = i
+			}
+		}
+		return compareOrdered(query+" noise", expectedNoise, noise)
+	}
+}
+
+func duplicatesProperty(toleranceKm float64) property {
+	return func(pts []Point) string {
+		// the connected components of Points within toleranceKm, by their first Point
+		group := make([]int, len(pts))
+		for i := range group {
+			group[i] = -1
+		}
+		var expected [][]Point
+		for i := range pts {
+			if group[i] >= 0 {
+				continue
+			}
+			g := len(expected)
+			group[i] = g
+			members := []int{i}
+			for m := 0; m < len(members); m++ {
+				for j := range pts {
+					if group[j] < 0 && oracleKm(pts[members[m]], pts[j]) <= toleranceKm {
+						group[j] = g
+						members = append(members, j)
+					}
+				}
+			}
+			sort.Ints(members)
+			var groupPts []Point
+			for _, j := range members {
+				groupPts = append(groupPts, pts[j])
+			}
+			expected = append(expected, groupPts)
+		}
+
+		actual := Duplicates(pts, toleranceKm)
+		query := fmt.Sprintf("Duplicates(%v)", toleranceKm)
+		if len(actual) != len(expected) {
+			return fmt.Sprintf("%s found %d groups, expected %d", query, len(actual), len(expected))
+		}
+		for g := range expected {
+			if failure := compareOrdered(fmt.Sprintf("%s group %d", query, g), expected[g], actual[g]); failure != "" {
+				return failure
+			}
+		}
+		return ""
+	}
+}
+
+// bruteForceOrder sorts the accepted Points by a distance function like a search does, and gets the first k
+func bruteForceOrder(pts []Point, k int, accept Accepter, dist func(pt Point) float64) []Point {
+	q := newPriorityQueue(len(pts))
+	for i, pt := range pts {
+		if accept(pt) {
+			q.PushPoint(pt, dist(pt), i)
+		}
+	}
+	var result []Point
+	for len(result) < k && q.Len() > 0 {
+		result = append(result, q.PopItem().point)
+	}
+	return result
+}
+
+// bruteForceFilter gets the accepted Points inside a region, and counts all Points inside it
+func bruteForceFilter(pts []Point, accept Accepter, inside func(pt Point) bool) ([]Point, int) {
+	var result []Point
+	count := 0
+	for _, pt := range pts {
+		if inside(pt) {
+			count++
+			if accept(pt) {
+				result = append(result, pt)
+			}
+		}
+	}
+	return result, count
+}
+
+func compareOrdered(query string, expected, actual []Point) string {
+	if len(expected) != len(actual) {
+		return fmt.Sprintf("%s found %d Points, expected %d", query, len(actual), len(expected))
+	}
+	for i := range expected {
+		if expected[i] != actual[i] {
+			return fmt.Sprintf("%s result %d is %v, expected %v", query, i, actual[i], expected[i])
+		}
+	}
+	return ""
+}
+
+func compareUnordered(query string, expected, actual []Point) string {
+	if len(expected) != len(actual) {
+		return fmt.Sprintf("%s found %d Points, expected %d", query, len(actual), len(expected))
+	}
+	found := make(map[Point]int)
+	for _, pt := range actual {
+		found[pt]++
+	}
+	for _, pt := range expected {
+		if found[pt] == 0 {
+			return fmt.Sprintf("%s did not find %v", query, pt)
+		}
+		found[pt]--
+	}
+	return ""
+}
+
+func compareDistances(query string, origin Point, expected, actual []Point) string {
+	if len(expected) != len(actual) {
+		return fmt.Sprintf("%s found %d Points, expected %d", query, len(actual), len(expected))
+	}
+	for i := range expected {
+		if d1, d2 := distanceKm(origin, expected[i]), distanceKm(origin, actual[i]); math.Abs(d1-d2) > 1e-6 {
+			return fmt.Sprintf("%s result %d is %v km away, expected %v km", query, i, d2, d1)
+		}
+	}
+	return ""
+}
+
+// oracleRanking is the order of Points that a search should find, by a key like the distance from the origin
+type oracleRanking struct {
+	points    []Point
+	key       func(pt Point) float64
+	tieKm     float64
+	ambiguous bool // whether nearly tied Points make the order depend on rounding
+}
+
+// oracleOrder orders the accepted Points like a search with TieEpsilonKm does, and gets the first k: the closest
+// remaining Point and every remaining Point within tieKm of it are tied, ordered by rank, then key, then load order
+func oracleOrder(pts []Point, k int, accept Accepter, key func(pt Point) float64, tieKm float64) oracleRanking {
+	var sorted []Point
+	for _, pt := range pts {
+		if accept(pt) {
+			sorted = append(sorted, pt)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return key(sorted[i]) < key(sorted[j]) })
+
+	ranking := oracleRanking{key: key, tieKm: tieKm}
+	var ends []float64
+	for rest := sorted; len(rest) > 0 && len(ranking.points) < k; {
+		end := key(rest[0]) + tieKm
+		n := 0
+		for n < len(rest) && key(rest[n]) <= end {
+			n++
+		}
+		tied := append([]Point(nil), rest[:n]...)
+		sort.SliceStable(tied, func(i, j int) bool { return rankOf(tied[i]) > rankOf(tied[j]) })
+		ranking.points = append(ranking.points, tied...)
+		ends = append(ends, end)
+		rest = rest[n:]
+	}
+	if len(ranking.points) > k {
+		ranking.points = ranking.points[:k]
+	}
+
+	// Points that are nearly tied with each other, or with the end of a tie, may be in either order
+	for i := 1; i < len(sorted) && len(ends) > 0 && key(sorted[i-1]) <= ends[len(ends)-1]+1e-6; i++ {
+		a, b := sorted[i-1], sorted[i]
+		if sameLocation := a.Lon() == b.Lon() && a.Lat() == b.Lat(); !sameLocation && key(b)-key(a) <= 1e-6 {
+			ranking.ambiguous = true
+		}
+		for _, end := range ends {
+			if d := math.Abs(key(sorted[i]) - end); tieKm > 0 && d > 0 && d <= 1e-6 {
+				ranking.ambiguous = true
+			}
+		}
+	}
+	return ranking
+}
+
+// compareRanked compares the results of a search with its oracle ranking. If nearly tied Points make the order
+// depend on rounding, results may only differ from the ranking by nearly tied Points.
+func compareRanked(query string, expected oracleRanking, actual []Point) string {
+	if !expected.ambiguous {
+		return compareOrdered(query, expected.points, actual)
+	}
+	if len(expected.points) != len(actual) {
+		return fmt.Sprintf("%s found %d Points, expected %d", query, len(actual), len(expected.points))
+	}
+	found := make(map[Point]bool)
+	for i, pt := range actual {
+		if found[pt] {
+			return fmt.Sprintf("%s found %v twice", query, pt)
+		}
+		found[pt] = true
+		if d1, d2 := expected.key(expected.points[i]), expected.key(pt); math.Abs(d1-d2) > expected.tieKm+1e-6 {
+			return fmt.Sprintf("%s result %d is %v at %v, expected %v at %v", query, i, pt, d2, expected.points[i], d1)
+		}
+	}
+	return ""
+}
+
+// oracleVec gets the unit vector of a location
+func oracleVec(pt Point) [3]float64 {
+	lon, lat := pt.Lon()*math.Pi/180, pt.Lat()*math.Pi/180
+	return [3]float64{math.Cos(lat) * math.Cos(lon), math.Cos(lat) * math.Sin(lon), math.Sin(lat)}
+}
+
+func oracleDot(a, b [3]float64) float64 { return a[0]*b[0] + a[1]*b[1] + a[2]*b[2] }
+
+func oracleCross(a, b [3]float64) [3]float64 {
+	return [3]float64{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+}
+
+func oracleNorm(a [3]float64) float64 { return math.Sqrt(oracleDot(a, a)) }
+
+// oracleAngle gets the angle between two vectors, which is accurate for small and large angles alike
+func oracleAngle(a, b [3]float64) float64 {
+	return math.Atan2(oracleNorm(oracleCross(a, b)), oracleDot(a, b))
+}
+
+// oracleKm gets the great-circle distance between two Points from the angle between their vectors
+func oracleKm(a, b Point) float64 { return oracleAngle(oracleVec(a), oracleVec(b)) * earthRadiusKm }
+
+// oracleSlantKm gets the straight-line distance between two Points with altitudes (see Altituder)
+func oracleSlantKm(a, b Point) float64 {
+	va, vb := oracleVec(a), oracleVec(b)
+	ra, rb := earthRadiusKm+a.(Altituder).Alt()/1000, earthRadiusKm+b.(Altituder).Alt()/1000
+	var d [3]float64
+	for i := range d {
+		d[i] = va[i]*ra - vb[i]*rb
+	}
+	return oracleNorm(d)
+}
+
+// oraclePath gets the distance from a Point to the closest point of a path, and how far along the path (both in
+// kilometers) that closest point is. Paths must not have antipodal edges.
+func oraclePath(path []Point, pt Point) (km, alongKm float64) {
+	p := oracleVec(pt)
+	km, alongKm = oracleKm(path[0], pt), 0
+	offset := 0.0
+	for i := 1; i < len(path); i++ {
+		a, b := oracleVec(path[i-1]), oracleVec(path[i])
+		length := oracleAngle(a, b)
+		dist, along := oracleAngle(p, b), length
+		if d := oracleAngle(p, a); d < dist {
+			dist, along = d, 0
+		}
+
+		// the closest point of the edge's great circle is the projection of the Point onto its plane
+		if n := oracleCross(a, b); oracleNorm(n) > 1e-12 {
+			n = [3]float64{n[0] / oracleNorm(n), n[1] / oracleNorm(n), n[2] / oracleNorm(n)}
+			var q [3]float64
+			for j := range q {
+				q[j] = p[j] - oracleDot(p, n)*n[j]
+			}
+			if oracleNorm(q) > 1e-12 && oracleAngle(a, q)+oracleAngle(q, b) <= length+1e-12 {
+				if d := oracleAngle(p, q); d < dist {
+					dist, along = d, oracleAngle(a, q)
+				}
+			}
+		}
+		if dist*earthRadiusKm < km {
+			km, alongKm = dist*earthRadiusKm, (offset+along)*earthRadiusKm
+		}
+		offset += length
+	}
+	return km, alongKm
+}
+
+// oracleInBox checks whether a Point is inside a bounding box that may cross the date line
+func oracleInBox(b bounds, pt Point) bool {
+	if pt.Lat() < b.MinLat || pt.Lat() > b.MaxLat {
+		return false
+	}
+	if b.MinLon > b.MaxLon {
+		return pt.Lon() >= b.MinLon || pt.Lon() <= b.MaxLon
+	}
+	return pt.Lon() >= b.MinLon && pt.Lon() <= b.MaxLon
+}
+
+// oracleConnected checks whether two core Points are connected by a chain of neighboring core Points
+func oracleConnected(neighbors [][]int, core []bool, from, to int) bool {
+	seen := map[int]bool{from: true}
+	queue := []int{from}
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		if i == to {
+			return true
+		}
+		for _, j := range neighbors[i] {
+			if core[j] && !seen[j] {
+				seen[j] = true
+				queue = append(queue, j)
+			}
+		}
+	}
+	return false
+}
+
+// testPointID gets the index of a random test Point, from its name
+func testPointID(pt Point) int {
+	id, _ := strconv.Atoi(pt.(*RankedPoint).Name)
+	return id
+}
+
+// testPointAttributes declares the parity of the index of random test Points, and the number of its digits
+func testPointAttributes() []Attribute {
+	return []Attribute{
+		{Name: "parity", Value: func(p Point) string { return fmt.Sprint(testPointID(p) % 2) }},
+		{Name: "digits", Value: func(p Point) string { return fmt.Sprint(len(p.(*RankedPoint).Name)) }},
+	}
+}
+
+// loadOrder gets the position of each Point in the order they were loaded
+func loadOrder(pts []Point) map[Point]int {
+	seq := make(map[Point]int)
+	for i, pt := range pts {
+		seq[pt] = i
+	}
+	return seq
+}
+
+// randomTestPoints gets n distinct Points (so that results can be compared by identity), a mix of uniformly
+// distributed Points, Points near the poles and the date line, duplicates and clusters, with a few different ranks
+func randomTestPoints(rnd *rand.Rand, n int) []Point {
+	pts := make([]Point, 0, n)
+	for i := 0; i < n; i++ {
+		pt := randomTestPoint(rnd, pts)
+		pts = append(pts, &RankedPoint{
+			Point: NewCoordinates(pt.Lon(), pt.Lat()),
+			Name:  fmt.Sprint(i),
+			Rank:  float64(rnd.Intn(3)),
+		})
+	}
+	return pts
+}
+
+// randomTestPoint gets a random location, which may be near an existing Point
+func randomTestPoint(rnd *rand.Rand, pts []Point) Point {
+	switch kind := rnd.Intn(6); {
+	case kind == 0 && len(pts) > 0: // duplicate
+		return pts[rnd.Intn(len(pts))]
+	case kind == 1 && len(pts) > 0: // cluster
+		pt := pts[rnd.Intn(len(pts))]
+		return NewCoordinates(wrapLon(pt.Lon()+rnd.NormFloat64()*0.01), math.Max(-90, math.Min(90, pt.Lat()+rnd.NormFloat64()*0.01)))
+	case kind == 2: // pole
+		lat := 90 - math.Pow(rnd.Float64(), 3)
+		if rnd.Intn(2) == 0 {
+			lat = -lat
+		}
+		return NewCoordinates(float64(rnd.Intn(8)*45-180), lat)
+	case kind == 3: // date line
+		lon := []float64{-180, 180, 179.999, -179.999}[rnd.Intn(4)]
+		return NewCoordinates(lon, rnd.Float64()*180-90)
+	default:
+		return NewCoordinates(rnd.Float64()*360-180, rnd.Float64()*180-90)
+	}
+}
+
+// randomTestPath gets a path of two to four random locations, without antipodal edges
+func randomTestPath(rnd *rand.Rand, pts []Point) []Point {
+	path := []Point{randomTestPoint(rnd, pts)}
+	for n := 2 + rnd.Intn(3); len(path) < n; {
+		next := randomTestPoint(rnd, pts)
+		if oracleKm(path[len(path)-1], next) < 179*math.Pi/180*earthRadiusKm {
+			path = append(path, next)
+		}
+	}
+	return path
+}
+
+// randomTestBox gets a random bounding box, which may cross the date line or include a pole
+func randomTestBox(rnd *rand.Rand) bounds {
+	lat1, lat2 := rnd.Float64()*180-90, rnd.Float64()*180-90
+	if rnd.Intn(4) == 0 {
+		lat2 = 90
+	}
+	return bounds{
+		MinLon: rnd.Float64()*360 - 180,
+		MinLat: math.Min(lat1, lat2),
+		MaxLon: rnd.Float64()*360 - 180,
+		MaxLat: math.Max(lat1, lat2),
+	}
+}
+
+// randomTestAccepter gets an Accepter that accepts every Point, or only some ranks
+func randomTestAccepter(rnd *rand.Rand) Accepter {
+	if rnd.Intn(2) == 0 {
+		return AcceptAny
+	}
+	rejected := float64(rnd.Intn(3))
+	return func(pt Point) bool { return pt.(*RankedPoint).Rank != rejected }
+}
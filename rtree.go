@@ -0,0 +1,293 @@
+package neighborhood
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// rtreeHilbertOrder is the number of bits per axis of the Hilbert curve that orders R-tree entries
+const rtreeHilbertOrder = 16
+
+// Bounder is an optional interface for Points that cover an area, like a city or a coverage zone
+type Bounder interface {
+	// Bounds gets the bounding box of the area. If minLon is greater than maxLon, the box crosses the date line.
+	Bounds() (minLon, minLat, maxLon, maxLat float64)
+}
+
+// RTree implements the Index interface with a static R-tree of bounding boxes (see Bounder), packed along a Hilbert
+// curve like the flatbush library. Points that do not implement Bounder are indexed as boxes without area.
+// Distances are great-circle distances to the closest point of each box, so areas that contain the origin are the
+// nearest. Like the KDTree, each call to Load rebuilds the whole index.
+type RTree struct {
+	sync.RWMutex
+	nodeSize int
+	points   []Point
+	leaves   int      // number of leaf entries, which come first in boxes
+	boxes    []bounds // bounding boxes of the leaf entries, followed by the nodes of each level, up to the root
+	indices  []int    // Point index of each leaf entry, or position of the first child of each node
+	levels   []int    // end position of each level in boxes, from the leaves up
+	wraps    bool     // whether a box crosses the date line, and is split into two leaf entries
+}
+
+// RTreeOptions defines configurable options for the RTree index
+type RTreeOptions struct {
+	// NodeSize is the maximum number of children of each node
+	NodeSize int
+}
+
+// DefaultRTreeOptions gets the default RTree options, which you can use directly or modify before creating an Index
+func DefaultRTreeOptions() RTreeOptions {
+	return RTreeOptions{
+		NodeSize: 16,
+	}
+}
+
+// NewRTreeIndex creates a new RTree Index implementation with given RTreeOptions
+func NewRTreeIndex(opts RTreeOptions) Index {
+	return &RTree{
+		nodeSize: int(math.Max(float64(opts.NodeSize), 2)),
+	}
+}
+
+// Load adds searchable Points to the Index.
+// Each call to Load will replace all Points in the Index with the provided Points.
+// Load mutates and returns the Index to allow call chaining.
+func (idx *RTree) Load(points ...Point) Index {
+	idx.Lock()
+	defer idx.Unlock()
+
+	// boxes that cross the date line are split in two, so that every box has minLon <= maxLon
+	idx.points = points
+	idx.boxes = make([]bounds, 0, len(points))
+	idx.indices = make([]int, 0, len(points))
+	idx.wraps = false
+	for i, pt := range points {
+		b := boundsOf(pt)
+		if b.MinLon > b.MaxLon {
+			idx.boxes = append(idx.boxes, bounds{MinLon: b.MinLon, MinLat: b.MinLat, MaxLon: 180, MaxLat: b.MaxLat})
+			idx.indices = append(idx.indices, i)
+			b.MinLon = -180
+			idx.wraps = true
+		}
+		idx.boxes = append(idx.boxes, b)
+		idx.indices = append(idx.indices, i)
+	}
+	idx.leaves = len(idx.boxes)
+	idx.levels = []int{idx.leaves}
+	if idx.leaves == 0 {
+		return idx
+	}
+
+	idx.sortLeaves()
+
+	// pack each level into nodes of consecutive boxes, until there is a single root node
+	for start, end := 0, idx.leaves; ; start, end = end, len(idx.boxes) {
+		for i := start; i < end; i += idx.nodeSize {
+			node := idx.boxes[i]
+			for _, b := range idx.boxes[i+1 : int(math.Min(float64(i+idx.nodeSize), float64(end)))] {
+				node = bounds{
+					MinLon: math.Min(node.MinLon, b.MinLon),
+					MinLat: math.Min(node.MinLat, b.MinLat),
+					MaxLon: math.Max(node.MaxLon, b.MaxLon),
+					MaxLat: math.Max(node.MaxLat, b.MaxLat),
+				}
+			}
+			idx.boxes = append(idx.boxes, node)
+			idx.indices = append(idx.indices, i)
+		}
+		idx.levels = append(idx.levels, len(idx.boxes))
+		if len(idx.boxes)-end == 1 {
+			break
+		}
+	}
+	return idx
+}
+
+// Add will update the index with the provided points, while persisting the existing points.
+// Add returns the Index after it is complete to allow call chaining. Add is as-expensive as Load
+func (idx *RTree) Add(points ...Point) Index {
+	return idx.Load(append(idx.points, points...)...)
+}
+
+// Nearby finds the k nearest Points to the origin that meet the Accepter criteria.
+// If there are multiple Points that are the same distance from the origin and the Points implement the Ranker
+// interface, the higher ranking Points will be preferred. Points with the same distance and rank are returned in the
+// order they were loaded (by Load, followed by Add). Nearby may return less than k results if it cannot find k
+// Points in the Index that meet the Accepter criteria.
+func (idx *RTree) Nearby(origin Point, k int, accept Accepter) []Point {
+	idx.RLock()
+	defer idx.RUnlock()
+
+	result := make([]Point, 0, k)
+	if idx.leaves == 0 {
+		return result
+	}
+	cosLat := math.Cos(origin.Lat() * rad)
+
+	var seen map[int]bool
+	if idx.wraps {
+		seen = make(map[int]bool)
+	}
+
+	q := newPriorityQueue(k)
+	q.PushNodeDist(rtreeNode(len(idx.boxes)-1), 0)
+	for len(result) < k {
+		itm := q.PopItem()
+		if itm == nil {
+			break
+		}
+		if itm.point != nil {
+			// the closer half of a box that crosses the date line is popped first
+			if seen != nil {
+				if seen[itm.seq] {
+					continue
+				}
+				seen[itm.seq] = true
+			}
+			result = append(result, itm.point)
+			continue
+		}
+
+		start, end := idx.children(int(itm.node.(rtreeNode)))
+		for i := start; i < end; i++ {
+			dist := boxDist(origin, cosLat, idx.boxes[i])
+			if i >= idx.leaves {
+				q.PushNodeDist(rtreeNode(i), dist)
+			} else if pt := idx.points[idx.indices[i]]; accept(pt) {
+				q.PushPoint(pt, dist, idx.indices[i])
+			}
+		}
+	}
+	return result
+}
+
+// Intersecting finds all Points whose bounding boxes intersect a bounding box and meet the Accepter criteria,
+// in no particular order. If minLon is greater than maxLon, the box crosses the date line.
+func (idx *RTree) Intersecting(minLon, minLat, maxLon, maxLat float64, accept Accepter) []Point {
+	idx.RLock()
+	defer idx.RUnlock()
+
+	var result []Point
+	if idx.leaves == 0 {
+		return result
+	}
+	queries := []bounds{{MinLon: minLon, MinLat: minLat, MaxLon: maxLon, MaxLat: maxLat}}
+	if minLon > maxLon {
+		queries = []bounds{
+			{MinLon: minLon, MinLat: minLat, MaxLon: 180, MaxLat: maxLat},
+			{MinLon: -180, MinLat: minLat, MaxLon: maxLon, MaxLat: maxLat},
+		}
+	}
+	intersects := func(b bounds) bool {
+		for _, q := range queries {
+			if b.MinLon <= q.MaxLon && b.MaxLon >= q.MinLon && b.MinLat <= q.MaxLat && b.MaxLat >= q.MinLat {
+				return true
+			}
+		}
+		return false
+	}
+
+	// both halves of a box that crosses the date line may intersect
+	var seen map[int]bool
+	if idx.wraps {
+		seen = make(map[int]bool)
+	}
+
+	stack := []int{len(idx.boxes) - 1}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		start, end := idx.children(node)
+		for i := start; i < end; i++ {
+			if !intersects(idx.boxes[i]) {
+				continue
+			}
+			if i >= idx.leaves {
+				stack = append(stack, i)
+				continue
+			}
+			id := idx.indices[i]
+			if seen != nil {
+				if seen[id] {
+					continue
+				}
+				seen[id] = true
+			}
+			if pt := idx.points[id]; accept(pt) {
+				result = append(result, pt)
+			}
+		}
+	}
+	return result
+}
+
+// children gets the range of positions of the children of a node
+func (idx *RTree) children(node int) (start, end int) {
+	start = idx.indices[node]
+	for _, levelEnd := range idx.levels {
+		if start < levelEnd {
+			return start, int(math.Min(float64(start+idx.nodeSize), float64(levelEnd)))
+		}
+	}
+	return start, start
+}
+
+// sortLeaves sorts the leaf entries by the Hilbert curve position of their centers, so that each node holds nearby
+// boxes
+func (idx *RTree) sortLeaves() {
+	extent := idx.boxes[0]
+	for _, b := range idx.boxes[1:] {
+		extent = bounds{
+			MinLon: math.Min(extent.MinLon, b.MinLon),
+			MinLat: math.Min(extent.MinLat, b.MinLat),
+			MaxLon: math.Max(extent.MaxLon, b.MaxLon),
+			MaxLat: math.Max(extent.MaxLat, b.MaxLat),
+		}
+	}
+	steps := float64(int(1)<<rtreeHilbertOrder - 1)
+	scale := func(v, min, max float64) int {
+		if max == min {
+			return 0
+		}
+		return int(math.Floor(steps * ((v - min) / (max - min))))
+	}
+
+	positions := make([]uint64, len(idx.boxes))
+	for i, b := range idx.boxes {
+		x := scale((b.MinLon+b.MaxLon)/2, extent.MinLon, extent.MaxLon)
+		y := scale((b.MinLat+b.MaxLat)/2, extent.MinLat, extent.MaxLat)
+		positions[i] = hilbertPos(0, x, y, rtreeHilbertOrder)
+	}
+	sort.Stable(&hilbertSorter{positions: positions, boxes: idx.boxes, indices: idx.indices})
+}
+
+// boundsOf gets the bounding box of a Point, which has no area unless the Point implements Bounder
+func boundsOf(pt Point) bounds {
+	if b, ok := pt.(Bounder); ok {
+		minLon, minLat, maxLon, maxLat := b.Bounds()
+		return bounds{MinLon: minLon, MinLat: minLat, MaxLon: maxLon, MaxLat: maxLat}
+	}
+	return bounds{MinLon: pt.Lon(), MinLat: pt.Lat(), MaxLon: pt.Lon(), MaxLat: pt.Lat()}
+}
+
+// rtreeNode is the position of a node in the boxes of an RTree
+type rtreeNode int
+
+// hilbertSorter sorts R-tree leaf entries by their Hilbert curve positions
+type hilbertSorter struct {
+	positions []uint64
+	boxes     []bounds
+	indices   []int
+}
+
+func (s *hilbertSorter) Len() int { return len(s.positions) }
+
+func (s *hilbertSorter) Less(i, j int) bool { return s.positions[i] < s.positions[j] }
+
+func (s *hilbertSorter) Swap(i, j int) {
+	s.positions[i], s.positions[j] = s.positions[j], s.positions[i]
+	s.boxes[i], s.boxes[j] = s.boxes[j], s.boxes[i]
+	s.indices[i], s.indices[j] = s.indices[j], s.indices[i]
+}
@@ -0,0 +1,211 @@
+package neighborhood
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+type AreaPoint struct {
+	Point
+	Name string
+	Box  bounds
+}
+
+func (p *AreaPoint) Bounds() (minLon, minLat, maxLon, maxLat float64) {
+	return p.Box.MinLon, p.Box.MinLat, p.Box.MaxLon, p.Box.MaxLat
+}
+
+func areaPoint(name string, minLon, minLat, maxLon, maxLat float64) *AreaPoint {
+	lon := (minLon + maxLon) / 2
+	if minLon > maxLon {
+		lon = wrapLon(lon + 180)
+	}
+	return &AreaPoint{
+		Point: NewCoordinates(lon, (minLat+maxLat)/2),
+		Name:  name,
+		Box:   bounds{MinLon: minLon, MinLat: minLat, MaxLon: maxLon, MaxLat: maxLat},
+	}
+}
+
+func areaPoints() []Point {
+	return []Point{
+		areaPoint("washington", -124.8, 45.5, -116.9, 49),
+		areaPoint("seattle", -122.46, 47.49, -122.22, 47.74),
+		areaPoint("tennessee", -90.3, 35, -81.6, 36.7),
+		areaPoint("fiji", 177, -19.2, -178.2, -16), // crosses the date line
+		areaPoint("kamchatka", 155.5, 50.8, 163.5, 62),
+		namedPoint("anchorage"),
+		namedPoint("tokyo"),
+	}
+}
+
+func nameOf(pt Point) string {
+	if area, ok := pt.(*AreaPoint); ok {
+		return area.Name
+	}
+	return pt.(*NamedPoint).Name
+}
+
+func TestRTree_Nearby(t *testing.T) {
+	idx := NewRTreeIndex(RTreeOptions{NodeSize: 2}).Load(areaPoints()...)
+
+	// areas that contain the origin are the nearest, in the order they were loaded
+	results := idx.Nearby(points["seattle"], 4, AcceptAny)
+	assertEqual(t, 4, len(results))
+	assertEqual(t, "washington", nameOf(results[0]))
+	assertEqual(t, "seattle", nameOf(results[1]))
+	assertEqual(t, "anchorage", nameOf(results[2]))
+	assertEqual(t, "tennessee", nameOf(results[3]))
+
+	// across the date line, each area is found once
+	results = idx.Nearby(NewCoordinates(-179, -17), 10, AcceptAny)
+	assertEqual(t, 7, len(results))
+	assertEqual(t, "fiji", nameOf(results[0]))
+	results = idx.Nearby(NewCoordinates(175, -17), 2, func(pt Point) bool {
+		return nameOf(pt) != "tokyo"
+	})
+	assertEqual(t, "fiji", nameOf(results[0]))
+	assertEqual(t, "kamchatka", nameOf(results[1]))
+
+	assertEqual(t, 0, len(NewRTreeIndex(DefaultRTreeOptions()).Nearby(NewCoordinates(0, 0), 10, AcceptAny)))
+}
+
+func TestRTree_Nearby_MatchesKDTree(t *testing.T) {
+	rnd := rand.New(rand.NewSource(47))
+	pts := make([]Point, 3_000)
+	for i := range pts {
+		pts[i] = &NamedPoint{Point: NewCoordinates(rnd.Float64()*360-180, rnd.Float64()*180-90)}
+	}
+	rtree := NewRTreeIndex(RTreeOptions{NodeSize: 4}).Load(pts[:1_000]...)
+	rtree.Add(pts[1_000:]...)
+	kd := NewKDTreeIndex(DefaultKDTreeOptions()).Load(pts...)
+
+	for _, origin := range []Point{NewCoordinates(0, 90), NewCoordinates(180, 0), NewCoordinates(-122, 47)} {
+		expected, results := kd.Nearby(origin, 25, AcceptAny), rtree.Nearby(origin, 25, AcceptAny)
+		assertEqual(t, len(expected), len(results))
+		for i := range expected {
+			assertEqual(t, expected[i], results[i])
+		}
+	}
+}
+
+func TestRTree_Nearby_BruteForce(t *testing.T) {
+	rnd := rand.New(rand.NewSource(47))
+	pts := randomAreas(rnd, 2_000)
+	idx := NewRTreeIndex(DefaultRTreeOptions()).Load(pts...)
+
+	for trial := 0; trial < 20; trial++ {
+		origin := NewCoordinates(rnd.Float64()*360-180, rnd.Float64()*180-90)
+		cosLat := math.Cos(origin.Lat() * rad)
+		results := idx.Nearby(origin, 30, AcceptAny)
+		assertEqual(t, 30, len(results))
+
+		// results are in order, and no other area is closer than the last one
+		last := 0.0
+		for _, pt := range results {
+			dist := areaDist(origin, cosLat, pt.(*AreaPoint))
+			assertEqual(t, true, dist >= last)
+			last = dist
+		}
+		closer := 0
+		for _, pt := range pts {
+			if areaDist(origin, cosLat, pt.(*AreaPoint)) < last {
+				closer++
+			}
+		}
+		assertEqual(t, true, closer < 30)
+	}
+}
+
+func TestRTree_Intersecting(t *testing.T) {
+	idx := NewRTreeIndex(RTreeOptions{NodeSize: 2}).Load(areaPoints()...).(*RTree)
+
+	names := func(pts []Point) map[string]bool {
+		found := map[string]bool{}
+		for _, pt := range pts {
+			assertEqual(t, false, found[nameOf(pt)])
+			found[nameOf(pt)] = true
+		}
+		return found
+	}
+
+	found := names(idx.Intersecting(-123, 47, -122, 48, AcceptAny))
+	assertEqual(t, 2, len(found))
+	assertEqual(t, true, found["washington"] && found["seattle"])
+
+	// a box across the date line finds the area across the date line once
+	found = names(idx.Intersecting(150, -20, -170, 70, AcceptAny))
+	assertEqual(t, 2, len(found))
+	assertEqual(t, true, found["fiji"] && found["kamchatka"])
+
+	found = names(idx.Intersecting(-180, -90, 180, 90, func(pt Point) bool {
+		return nameOf(pt) != "tokyo"
+	}))
+	assertEqual(t, 6, len(found))
+	assertEqual(t, 0, len(idx.Intersecting(0, 0, 10, 10, AcceptAny)))
+	assertEqual(t, 0, len(NewRTreeIndex(DefaultRTreeOptions()).(*RTree).Intersecting(-180, -90, 180, 90, AcceptAny)))
+}
+
+func TestRTree_Intersecting_BruteForce(t *testing.T) {
+	rnd := rand.New(rand.NewSource(47))
+	pts := randomAreas(rnd, 2_000)
+	idx := NewRTreeIndex(DefaultRTreeOptions()).Load(pts...).(*RTree)
+
+	for trial := 0; trial < 50; trial++ {
+		q := randomBox(rnd, 30)
+		expected := 0
+		for _, pt := range pts {
+			if boxesIntersect(q, pt.(*AreaPoint).Box) {
+				expected++
+			}
+		}
+		assertEqual(t, expected, len(idx.Intersecting(q.MinLon, q.MinLat, q.MaxLon, q.MaxLat, AcceptAny)))
+	}
+}
+
+// randomAreas gets areas of up to 5 degrees, some of which cross the date line
+func randomAreas(rnd *rand.Rand, n int) []Point {
+	pts := make([]Point, n)
+	for i := range pts {
+		b := randomBox(rnd, 5)
+		pts[i] = areaPoint("", b.MinLon, b.MinLat, b.MaxLon, b.MaxLat)
+	}
+	return pts
+}
+
+// randomBox gets a box of up to maxDeg degrees wide and high, which crosses the date line if MinLon > MaxLon
+func randomBox(rnd *rand.Rand, maxDeg float64) bounds {
+	lon, lat := rnd.Float64()*360-180, rnd.Float64()*(180-maxDeg)-90
+	return bounds{
+		MinLon: lon,
+		MinLat: lat,
+		MaxLon: wrapLon(lon + rnd.Float64()*maxDeg),
+		MaxLat: lat + rnd.Float64()*maxDeg,
+	}
+}
+
+// boxesIntersect checks whether two boxes that may cross the date line intersect
+func boxesIntersect(a, b bounds) bool {
+	if a.MinLat > b.MaxLat || b.MinLat > a.MaxLat {
+		return false
+	}
+	lonIn := func(lon float64, box bounds) bool {
+		if box.MinLon <= box.MaxLon {
+			return lon >= box.MinLon && lon <= box.MaxLon
+		}
+		return lon >= box.MinLon || lon <= box.MaxLon
+	}
+	return lonIn(a.MinLon, b) || lonIn(b.MinLon, a)
+}
+
+// areaDist gets the distance from an origin to an area by splitting it at the date line
+func areaDist(origin Point, cosLat float64, area *AreaPoint) float64 {
+	b := area.Box
+	if b.MinLon <= b.MaxLon {
+		return boxDist(origin, cosLat, b)
+	}
+	east, west := b, b
+	east.MaxLon, west.MinLon = 180, -180
+	return math.Min(boxDist(origin, cosLat, east), boxDist(origin, cosLat, west))
+}
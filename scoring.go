@@ -0,0 +1,77 @@
+package neighborhood
+
+import (
+	"math"
+)
+
+// Scorer combines the distance from the origin to a Point (in kilometers) and the Point rank into a score.
+// Lower scores are better. Scores must never decrease as distance increases, and never increase as rank increases,
+// so that the best score of any Point in a kd-tree node can be bounded by its closest distance and highest rank.
+type Scorer func(distanceKm, rank float64) float64
+
+// RankPenalty gets a Scorer that prefers higher ranking Points by subtracting kmPerRank kilometers from the
+// distance for each unit of rank
+func RankPenalty(kmPerRank float64) Scorer {
+	return func(distanceKm, rank float64) float64 {
+		return distanceKm - kmPerRank*rank
+	}
+}
+
+// NearbyScored finds the k Points with the lowest score that meet the Accepter criteria, where the score combines
+// the distance from the origin and the Point rank (see Ranker). Points that do not implement Ranker have a rank
+// of zero. If there are multiple Points with the same score, the higher ranking Points will be preferred.
+// NearbyScored may return less than k results if it cannot find k Points in the Index that meet the Accepter
+// criteria.
+func (idx *KDTree) NearbyScored(origin Point, k int, accept Accepter, score Scorer) []Point {
+	idx.RLock()
+	defer idx.RUnlock()
+
+	idx.maxRanksOnce.Do(idx.buildMaxRanks)
+
+	cosLat := math.Cos(origin.Lat() * rad)
+	search := idx.newSearch(accept,
+		func(i int) float64 {
+			distKm := haverSinToKm(haverSinDist(origin, idx.coords[2*i], idx.coords[2*i+1], cosLat))
+			return score(distKm, rankOf(idx.points[idx.ids[i]]))
+		},
+		func(node *kdTreeNode) float64 {
+			// the closest possible point with the highest rank in the node has the best possible score
+			distKm := haverSinToKm(boxDist(origin, cosLat, node.bounds))
+			return score(distKm, idx.maxRank(node))
+		},
+	)
+	return search.take(k)
+}
+
+// maxRank gets the highest rank of the points inside a node
+func (idx *KDTree) maxRank(node *kdTreeNode) float64 {
+	if node.Right < node.Left {
+		return math.Inf(-1)
+	}
+	// each node has a distinct middle index, since a node's middle point is not part of either child node
+	return idx.maxRanks[(node.Left+node.Right)>>1]
+}
+
+// buildMaxRanks stores the highest rank of the points inside every node
+func (idx *KDTree) buildMaxRanks() {
+	idx.maxRanks = make([]float64, len(idx.ids))
+
+	var build func(node *kdTreeNode) float64
+	build = func(node *kdTreeNode) float64 {
+		if node.Right < node.Left {
+			return math.Inf(-1)
+		}
+		best := math.Inf(-1)
+		if idx.isLeaf(node) {
+			for i := node.Left; i <= node.Right; i++ {
+				best = math.Max(best, rankOf(idx.points[idx.ids[i]]))
+			}
+		} else {
+			m, leftNode, rightNode := idx.split(node)
+			best = math.Max(rankOf(idx.points[idx.ids[m]]), math.Max(build(leftNode), build(rightNode)))
+		}
+		idx.maxRanks[(node.Left+node.Right)>>1] = best
+		return best
+	}
+	build(idx.root())
+}
@@ -0,0 +1,84 @@
+package neighborhood
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestKDTree_NearbyScored(t *testing.T) {
+	pts := []Point{
+		&RankedPoint{Point: points["seattle"], Name: "seattle", Rank: 0},
+		&RankedPoint{Point: points["woodinville"], Name: "woodinville", Rank: 10},
+		&RankedPoint{Point: points["memphis"], Name: "memphis", Rank: 1_000},
+	}
+	idx := NewIndex().Load(pts...).(*KDTree)
+	origin := NewCoordinates(-122.4, 47.6)
+
+	// without a penalty, distance wins
+	results := idx.NearbyScored(origin, 3, AcceptAny, RankPenalty(0))
+	assertEqual(t, 3, len(results))
+	assertEqual(t, "seattle", results[0].(*RankedPoint).Name)
+	assertEqual(t, "woodinville", results[1].(*RankedPoint).Name)
+	assertEqual(t, "memphis", results[2].(*RankedPoint).Name)
+
+	// woodinville is ~24 km farther, but 10 rank is worth 30 km
+	results = idx.NearbyScored(origin, 2, AcceptAny, RankPenalty(3))
+	assertEqual(t, 2, len(results))
+	assertEqual(t, "woodinville", results[0].(*RankedPoint).Name)
+	assertEqual(t, "seattle", results[1].(*RankedPoint).Name)
+
+	// memphis is ~3000 km farther, but 1000 rank is worth 5000 km
+	results = idx.NearbyScored(origin, 1, AcceptAny, RankPenalty(5))
+	assertEqual(t, "memphis", results[0].(*RankedPoint).Name)
+
+	results = idx.NearbyScored(origin, 3, func(pt Point) bool {
+		return pt.(*RankedPoint).Name != "memphis"
+	}, RankPenalty(5))
+	assertEqual(t, 2, len(results))
+}
+
+func TestKDTree_NearbyScored_Random(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+	pts := make([]Point, 5_000)
+	for i := range pts {
+		pts[i] = &RankedPoint{
+			Point: NewCoordinates(rnd.Float64()*360-180, rnd.Float64()*180-90),
+			Rank:  float64(rnd.Intn(100)),
+		}
+	}
+	idx := NewKDTreeIndex(KDTreeOptions{NodeSize: 8}).Load(pts...).(*KDTree)
+	score := RankPenalty(20)
+
+	for trial := 0; trial < 10; trial++ {
+		origin := NewCoordinates(rnd.Float64()*360-180, rnd.Float64()*180-90)
+
+		// compare with the scores of all points
+		var expected []float64
+		for _, pt := range pts {
+			expected = append(expected, score(distanceKm(origin, pt), pt.(Ranker).GetRank()))
+		}
+		sort.Float64s(expected)
+
+		results := idx.NearbyScored(origin, 10, AcceptAny, score)
+		assertEqual(t, 10, len(results))
+		for i, result := range results {
+			actual := score(distanceKm(origin, result), result.(Ranker).GetRank())
+			assertEqual(t, int(expected[i]*1000), int(actual*1000))
+		}
+	}
+}
+
+func TestKDTree_NearbyScored_Reload(t *testing.T) {
+	idx := NewIndex().Load(&RankedPoint{Point: points["memphis"], Name: "memphis", Rank: 1}).(*KDTree)
+	origin := NewCoordinates(-122.4, 47.6)
+	assertEqual(t, 1, len(idx.NearbyScored(origin, 1, AcceptAny, RankPenalty(1))))
+
+	// rank bounds are rebuilt after each Load
+	idx.Add(&RankedPoint{Point: points["tokyo"], Name: "tokyo", Rank: 100_000})
+	results := idx.NearbyScored(origin, 1, AcceptAny, RankPenalty(1))
+	assertEqual(t, "tokyo", results[0].(*RankedPoint).Name)
+
+	assertEqual(t, 0, len(NewIndex().(*KDTree).NearbyScored(origin, 1, AcceptAny, RankPenalty(1))))
+	assertEqual(t, 0, len((&KDTree{}).NearbyScored(origin, 1, AcceptAny, RankPenalty(1))))
+}
@@ -0,0 +1,178 @@
+package neighborhood
+
+import (
+	"math"
+	"sync"
+)
+
+// ShardedIndex implements the Index interface by partitioning Points into longitude bands, with a kd-tree per band.
+// Add only rebuilds the shards of the added Points, in parallel, and searches keep using the previous kd-trees of
+// those shards until their rebuilds are complete. Nearby merges the results of the shards, and only searches a shard
+// once the results reach the distance to the bounding box of its Points.
+type ShardedIndex struct {
+	sync.RWMutex            // guards the shards, which are replaced (never changed) by rebuilds
+	mu           sync.Mutex // serializes changes to the Points
+	opts         ShardedOptions
+	shards       []*shard
+	seq          int // insertion order of the next Point
+}
+
+// ShardedOptions defines configurable options for the ShardedIndex
+type ShardedOptions struct {
+	// Shards is the number of longitude bands
+	Shards int
+
+	// KDTree defines the options for the kd-tree of each shard. TieEpsilonKm groups tied Points across all shards.
+	// Altitude mode searches every shard, since bounding boxes do not bound slant ranges.
+	KDTree KDTreeOptions
+}
+
+// DefaultShardedOptions gets the default ShardedIndex options, which you can use directly or modify before creating
+// an Index
+func DefaultShardedOptions() ShardedOptions {
+	return ShardedOptions{
+		Shards: 16,
+		KDTree: DefaultKDTreeOptions(),
+	}
+}
+
+// shard holds the Points of a longitude band. Shards are never changed once built.
+type shard struct {
+	points []Point
+	seqs   []int // insertion order of each Point in the ShardedIndex
+	tree   *KDTree
+	bounds // bounding box of the Points
+}
+
+// NewShardedIndex creates a new ShardedIndex with given ShardedOptions
+func NewShardedIndex(opts ShardedOptions) *ShardedIndex {
+	opts.Shards = int(math.Max(float64(opts.Shards), 1))
+	return &ShardedIndex{
+		opts:   opts,
+		shards: make([]*shard, opts.Shards),
+	}
+}
+
+// Nearby finds the k nearest Points to the origin that meet the Accepter criteria.
+// If there are multiple Points that are the same distance from the origin and the Points implement the Ranker
+// interface, the higher ranking Points will be preferred. Points with the same distance and rank are returned in the
+// order they were loaded (by Load, followed by Add). Nearby may return less than k results if it cannot find k
+// Points in the Index that meet the Accepter criteria.
+func (si *ShardedIndex) Nearby(origin Point, k int, accept Accepter) []Point {
+	return si.nearbySearch(origin, accept).take(k)
+}
+
+// nearbySearch creates a merged search of the shards, which starts searching each shard when it is reached
+func (si *ShardedIndex) nearbySearch(origin Point, accept Accepter) *mergedSearch {
+	si.RLock()
+	shards := si.shards
+	si.RUnlock()
+
+	cosLat := math.Cos(origin.Lat() * rad)
+	var pending []pendingSearch
+	var tieEnd func(dist float64) float64
+	for _, s := range shards {
+		if s == nil {
+			continue
+		}
+		s := s
+		tieEnd = s.tree.nearbyTieEnd() // the same for every shard
+		bound := math.Inf(-1)
+		if !si.opts.KDTree.Altitude {
+			bound = loosen(boxDist(origin, cosLat, s.bounds))
+		}
+		pending = append(pending, pendingSearch{
+			bound: bound,
+			seqs:  s.seqs,
+			start: func() *kdSearch {
+				// the kd-tree of a shard is never changed, so it does not need to be locked
+				return s.tree.nearbySearch(origin, accept)
+			},
+		})
+	}
+	return newPendingMergedSearch(pending, tieEnd)
+}
+
+// Load will replace all Points in the Index with the provided Points.
+// Load mutates and returns the Index to allow call chaining.
+func (si *ShardedIndex) Load(points ...Point) Index {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+
+	si.seq = 0
+	si.rebuild(make([]*shard, si.opts.Shards), points)
+	return si
+}
+
+// Add will update the index with the provided points, while persisting the existing points.
+// Only the shards of the provided points are rebuilt.
+// Add returns the Index after it is complete to allow call chaining.
+func (si *ShardedIndex) Add(points ...Point) Index {
+	si.mu.Lock()
+	defer si.mu.Unlock()
+
+	si.RLock()
+	shards := si.shards
+	si.RUnlock()
+	si.rebuild(shards, points)
+	return si
+}
+
+// rebuild adds points to the shards, rebuilds the changed shards in parallel and replaces them.
+// The caller is responsible for serializing changes.
+func (si *ShardedIndex) rebuild(shards []*shard, points []Point) {
+	added := make(map[int][]Point)
+	addedSeqs := make(map[int][]int)
+	for _, pt := range points {
+		i := si.shardOf(pt)
+		added[i] = append(added[i], pt)
+		addedSeqs[i] = append(addedSeqs[i], si.seq)
+		si.seq++
+	}
+
+	rebuilt := make([]*shard, len(shards))
+	copy(rebuilt, shards)
+	var wg sync.WaitGroup
+	for i := range added {
+		old := rebuilt[i]
+		if old == nil {
+			old = &shard{}
+		}
+		s := &shard{
+			// copy, so that the Points of the previous shard never change
+			points: append(append([]Point{}, old.points...), added[i]...),
+			seqs:   append(append([]int{}, old.seqs...), addedSeqs[i]...),
+			tree:   NewKDTreeIndex(si.opts.KDTree).(*KDTree),
+		}
+		rebuilt[i] = s
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.tree.Load(s.points...)
+			s.bounds = pointBounds(s.points)
+		}()
+	}
+	wg.Wait()
+
+	si.Lock()
+	si.shards = rebuilt
+	si.Unlock()
+}
+
+// shardOf gets the shard of a Point by its longitude band
+func (si *ShardedIndex) shardOf(pt Point) int {
+	band := 360 / float64(si.opts.Shards)
+	return int(math.Min(math.Floor((wrapLon(pt.Lon())+180)/band), float64(si.opts.Shards-1)))
+}
+
+// pointBounds gets the bounding box of Points that do not cross the date line
+func pointBounds(points []Point) bounds {
+	b := bounds{MinLon: math.Inf(1), MinLat: math.Inf(1), MaxLon: math.Inf(-1), MaxLat: math.Inf(-1)}
+	for _, pt := range points {
+		lon := wrapLon(pt.Lon())
+		b.MinLon, b.MaxLon = math.Min(b.MinLon, lon), math.Max(b.MaxLon, lon)
+		b.MinLat, b.MaxLat = math.Min(b.MinLat, pt.Lat()), math.Max(b.MaxLat, pt.Lat())
+	}
+	return b
+}
@@ -0,0 +1,127 @@
+package neighborhood
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestShardedIndex_Nearby(t *testing.T) {
+	si := NewShardedIndex(DefaultShardedOptions()).Load(namedPoints()...)
+
+	results := si.Nearby(NewCoordinates(-115, 45), 3, AcceptAny)
+	assertEqual(t, 3, len(results))
+	assertEqual(t, "woodinville", results[0].(*NamedPoint).Name)
+	assertEqual(t, "seattle", results[1].(*NamedPoint).Name)
+	assertEqual(t, "memphis", results[2].(*NamedPoint).Name)
+
+	// across the date line
+	results = si.Nearby(NewCoordinates(-175, 60), 3, func(pt Point) bool {
+		return pt.(*NamedPoint).Name != "anchorage"
+	})
+	assertEqual(t, "eastrussia", results[0].(*NamedPoint).Name)
+	assertEqual(t, "seattle", results[1].(*NamedPoint).Name)
+	assertEqual(t, "woodinville", results[2].(*NamedPoint).Name)
+
+	assertEqual(t, 8, len(si.Nearby(NewCoordinates(-175, 60), 10, AcceptAny)))
+	assertEqual(t, 0, len(NewShardedIndex(DefaultShardedOptions()).Nearby(NewCoordinates(0, 0), 10, AcceptAny)))
+}
+
+func TestShardedIndex_Nearby_Ranked(t *testing.T) {
+	// tied Points in different shards are returned in the order they were loaded
+	pts := []Point{
+		&RankedPoint{Point: NewCoordinates(-10, 0), Name: "west-less-important", Rank: 1},
+		&RankedPoint{Point: NewCoordinates(10, 0), Name: "east-less-important", Rank: 1},
+		&RankedPoint{Point: NewCoordinates(-10, 0), Name: "west-same-importance", Rank: 1},
+		&RankedPoint{Point: NewCoordinates(10, 0), Name: "east-more-important", Rank: 5},
+	}
+	si := NewShardedIndex(ShardedOptions{Shards: 2, KDTree: DefaultKDTreeOptions()}).Load(pts[:2]...)
+	si.Add(pts[2:]...)
+
+	results := si.Nearby(NewCoordinates(0, 0), 4, AcceptAny)
+	assertEqual(t, "east-more-important", results[0].(*RankedPoint).Name)
+	assertEqual(t, "west-less-important", results[1].(*RankedPoint).Name)
+	assertEqual(t, "east-less-important", results[2].(*RankedPoint).Name)
+	assertEqual(t, "west-same-importance", results[3].(*RankedPoint).Name)
+}
+
+func TestShardedIndex_Nearby_TieEpsilon(t *testing.T) {
+	// Points within TieEpsilonKm are tied across shards, so the farther Point with a higher rank is preferred
+	pts := []Point{
+		&RankedPoint{Point: NewCoordinates(-10, 0), Name: "west", Rank: 1},
+		&RankedPoint{Point: NewCoordinates(10.01, 0), Name: "east-more-important", Rank: 5},
+		&RankedPoint{Point: NewCoordinates(-20, 0), Name: "far-west", Rank: 9},
+	}
+	opts := ShardedOptions{Shards: 2, KDTree: DefaultKDTreeOptions()}
+	opts.KDTree.TieEpsilonKm = 5
+	si := NewShardedIndex(opts).Load(pts...)
+
+	results := si.Nearby(NewCoordinates(0, 0), 3, AcceptAny)
+	assertEqual(t, "east-more-important", results[0].(*RankedPoint).Name)
+	assertEqual(t, "west", results[1].(*RankedPoint).Name)
+	assertEqual(t, "far-west", results[2].(*RankedPoint).Name)
+}
+
+func TestShardedIndex_Nearby_Pruning(t *testing.T) {
+	si := NewShardedIndex(ShardedOptions{Shards: 36, KDTree: DefaultKDTreeOptions()})
+	si.Load(globalPoints(10_000)...)
+
+	// a nearby search only searches the shards that it reaches
+	search := si.nearbySearch(namedPoint("seattle"), AcceptAny)
+	assertEqual(t, 10, len(search.take(10)))
+	assertEqual(t, true, len(search.searches) <= 3)
+
+	// altitude mode searches every shard
+	opts := DefaultShardedOptions()
+	opts.KDTree.Altitude = true
+	si = NewShardedIndex(opts)
+	si.Load(globalPoints(1_000)...)
+	search = si.nearbySearch(namedPoint("seattle"), AcceptAny)
+	assertEqual(t, 10, len(search.take(10)))
+	assertEqual(t, opts.Shards, len(search.searches))
+}
+
+func TestShardedIndex_Nearby_MatchesBruteForce(t *testing.T) {
+	rnd := rand.New(rand.NewSource(50))
+	pts := randomTestPoints(rnd, 3_000)
+	for _, shards := range []int{1, 7, 64} {
+		si := NewShardedIndex(ShardedOptions{Shards: shards, KDTree: KDTreeOptions{NodeSize: 8}})
+		si.Load(pts[:1_000]...)
+		si.Add(pts[1_000:2_000]...)
+		si.Add(pts[2_000:]...)
+		brute := NewBruteForceIndex().Load(pts...)
+
+		for trial := 0; trial < 20; trial++ {
+			origin := randomTestPoint(rnd, pts)
+			accept := randomTestAccepter(rnd)
+			expected, results := brute.Nearby(origin, 50, accept), si.Nearby(origin, 50, accept)
+			assertEqual(t, "", compareOrdered("ShardedIndex.Nearby", expected, results))
+		}
+	}
+}
+
+func TestShardedIndex_Concurrent(t *testing.T) {
+	si := NewShardedIndex(DefaultShardedOptions())
+	si.Load(globalPoints(1_000)...)
+	origin := namedPoint("seattle")
+
+	var wg sync.WaitGroup
+	found := make(chan int, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			si.Add(globalPoints(100)...)
+		}()
+		go func() {
+			defer wg.Done()
+			found <- len(si.Nearby(origin, 10, AcceptAny))
+		}()
+	}
+	wg.Wait()
+	close(found)
+	for n := range found {
+		assertEqual(t, 10, n)
+	}
+	assertEqual(t, 1_400, len(si.Nearby(origin, 2_000, AcceptAny)))
+}
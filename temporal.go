@@ -0,0 +1,177 @@
+package neighborhood
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Timestamper is an optional interface to define when a Point was recorded
+type Timestamper interface {
+	// Timestamp gets the time the Point was recorded
+	Timestamp() time.Time
+}
+
+// TemporalIndex implements the Index interface with a kd-tree per time bucket, so that searches within a time range
+// skip whole buckets outside of the range instead of filtering their Points one by one. Searches also only search a
+// bucket once the results reach the distance to the bounding box of its Points.
+// Points are bucketed by their Timestamp (see Timestamper); Points that do not implement Timestamper have a zero
+// timestamp.
+type TemporalIndex struct {
+	sync.RWMutex
+	opts    TemporalOptions
+	buckets []*timeBucket // sorted by start time
+	seq     int           // insertion order of the next Point
+}
+
+// TemporalOptions defines configurable options for the TemporalIndex
+type TemporalOptions struct {
+	BucketDuration time.Duration // time span of each bucket, or one hour if not positive
+	KDTree         KDTreeOptions // options for the kd-tree of each bucket; TieEpsilonKm groups ties across buckets
+}
+
+// DefaultTemporalOptions gets the default TemporalIndex options, which you can use directly or modify before
+// creating an Index
+func DefaultTemporalOptions() TemporalOptions {
+	return TemporalOptions{
+		BucketDuration: time.Hour,
+		KDTree:         DefaultKDTreeOptions(),
+	}
+}
+
+// timeBucket holds the Points recorded within a bucket's time span
+type timeBucket struct {
+	start  time.Time
+	end    time.Time
+	points []Point
+	seqs   []int // insertion order of each Point in the TemporalIndex
+	tree   *KDTree
+	bounds // bounding box of the Points
+}
+
+// NewTemporalIndex creates a new TemporalIndex with given TemporalOptions
+func NewTemporalIndex(opts TemporalOptions) *TemporalIndex {
+	if opts.BucketDuration <= 0 {
+		opts.BucketDuration = DefaultTemporalOptions().BucketDuration
+	}
+	return &TemporalIndex{
+		opts: opts,
+	}
+}
+
+// Nearby finds the k nearest Points to the origin that meet the Accepter criteria, regardless of their timestamps.
+// See Index for details.
+func (ti *TemporalIndex) Nearby(origin Point, k int, accept Accepter) []Point {
+	return ti.NearbyBetween(origin, k, time.Time{}, time.Time{}, accept)
+}
+
+// NearbyBetween finds the k nearest Points to the origin that were recorded in the time range [from, to) and meet the
+// Accepter criteria. A zero from or to leaves that end of the range open. Buckets outside of the time range are
+// skipped entirely, and only Points of buckets that are partially inside it are checked against the time range.
+// Results are ordered like Nearby, and Points with the same distance and rank are returned in the order they were
+// loaded (by Load, followed by Add).
+func (ti *TemporalIndex) NearbyBetween(origin Point, k int, from, to time.Time, accept Accepter) []Point {
+	ti.RLock()
+	defer ti.RUnlock()
+
+	cosLat := math.Cos(origin.Lat() * rad)
+	var pending []pendingSearch
+	var tieEnd func(dist float64) float64
+	for _, b := range ti.buckets {
+		if (!to.IsZero() && !b.start.Before(to)) || (!from.IsZero() && !b.end.After(from)) {
+			continue // outside of the time range
+		}
+		bucketAccept := accept
+		if (!from.IsZero() && b.start.Before(from)) || (!to.IsZero() && b.end.After(to)) {
+			// partially inside the time range
+			bucketAccept = func(p Point) bool {
+				ts := timestamp(p)
+				return (from.IsZero() || !ts.Before(from)) && (to.IsZero() || ts.Before(to)) && accept(p)
+			}
+		}
+		b := b
+		tieEnd = b.tree.nearbyTieEnd() // the same for every bucket
+		bound := math.Inf(-1)
+		if !ti.opts.KDTree.Altitude {
+			// bounding boxes do not bound slant ranges
+			bound = loosen(boxDist(origin, cosLat, b.bounds))
+		}
+		pending = append(pending, pendingSearch{
+			bound: bound,
+			seqs:  b.seqs,
+			start: func() *kdSearch {
+				return b.tree.nearbySearch(origin, bucketAccept)
+			},
+		})
+	}
+	return newPendingMergedSearch(pending, tieEnd).take(k)
+}
+
+// Load will replace all Points in the Index with the provided Points.
+// Load mutates and returns the Index to allow call chaining.
+func (ti *TemporalIndex) Load(points ...Point) Index {
+	ti.Lock()
+	defer ti.Unlock()
+
+	ti.buckets = nil
+	ti.seq = 0
+	ti.add(points)
+	return ti
+}
+
+// Add will update the index with the provided points, while persisting the existing points.
+// Only the buckets of the provided points are rebuilt.
+// Add returns the Index after it is complete to allow call chaining.
+func (ti *TemporalIndex) Add(points ...Point) Index {
+	ti.Lock()
+	defer ti.Unlock()
+
+	ti.add(points)
+	return ti
+}
+
+// add adds points to their buckets and rebuilds the kd-trees of those buckets. The caller is responsible for locking.
+func (ti *TemporalIndex) add(points []Point) {
+	changed := make(map[*timeBucket]bool)
+	for _, pt := range points {
+		b := ti.bucket(timestamp(pt))
+		b.points = append(b.points, pt)
+		b.seqs = append(b.seqs, ti.seq)
+		ti.seq++
+		changed[b] = true
+	}
+	for b := range changed {
+		b.tree.Load(b.points...)
+		b.bounds = pointBounds(b.points)
+	}
+}
+
+// bucket gets the bucket for a timestamp, creating it if needed
+func (ti *TemporalIndex) bucket(ts time.Time) *timeBucket {
+	start := ts.Truncate(ti.opts.BucketDuration)
+	i := sort.Search(len(ti.buckets), func(i int) bool {
+		return !ti.buckets[i].start.Before(start)
+	})
+	if i < len(ti.buckets) && ti.buckets[i].start.Equal(start) {
+		return ti.buckets[i]
+	}
+
+	b := &timeBucket{
+		start: start,
+		end:   start.Add(ti.opts.BucketDuration),
+		tree:  NewKDTreeIndex(ti.opts.KDTree).(*KDTree),
+	}
+	ti.buckets = append(ti.buckets, nil)
+	copy(ti.buckets[i+1:], ti.buckets[i:])
+	ti.buckets[i] = b
+	return b
+}
+
+// timestamp gets the Point timestamp if it implements the optional Timestamper interface, or the zero time otherwise
+func timestamp(p Point) time.Time {
+	if ts, ok := p.(Timestamper); ok {
+		return ts.Timestamp()
+	}
+	return time.Time{}
+}
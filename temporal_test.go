@@ -0,0 +1,161 @@
+package neighborhood
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+type TimestampedPoint struct {
+	Point
+	Name string
+	Time time.Time
+}
+
+func (p *TimestampedPoint) Timestamp() time.Time {
+	return p.Time
+}
+
+type rankedTimestampedPoint struct {
+	*RankedPoint
+	Time time.Time
+}
+
+func (p *rankedTimestampedPoint) Timestamp() time.Time {
+	return p.Time
+}
+
+var temporalStart = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func temporalPoints() []Point {
+	return []Point{
+		&TimestampedPoint{Point: points["seattle"], Name: "seattle-old", Time: temporalStart},
+		&TimestampedPoint{Point: points["woodinville"], Name: "woodinville", Time: temporalStart.Add(90 * time.Minute)},
+		&TimestampedPoint{Point: points["seattle"], Name: "seattle-new", Time: temporalStart.Add(150 * time.Minute)},
+		&TimestampedPoint{Point: points["memphis"], Name: "memphis", Time: temporalStart.Add(100 * time.Minute)},
+		namedPoint("tokyo"), // no timestamp
+	}
+}
+
+func TestTemporalIndex_Nearby(t *testing.T) {
+	ti := NewTemporalIndex(DefaultTemporalOptions()).Load(temporalPoints()...)
+	origin := NewCoordinates(-122, 47)
+
+	results := ti.Nearby(origin, 5, AcceptAny)
+	assertEqual(t, 5, len(results))
+	assertEqual(t, "seattle-old", results[0].(*TimestampedPoint).Name)
+	assertEqual(t, "seattle-new", results[1].(*TimestampedPoint).Name)
+	assertEqual(t, "woodinville", results[2].(*TimestampedPoint).Name)
+	assertEqual(t, "memphis", results[3].(*TimestampedPoint).Name)
+	assertEqual(t, "tokyo", results[4].(*NamedPoint).Name)
+
+	results = ti.Nearby(origin, 5, func(p Point) bool {
+		_, ok := p.(*NamedPoint)
+		return ok
+	})
+	assertEqual(t, 1, len(results))
+}
+
+func TestTemporalIndex_NearbyBetween(t *testing.T) {
+	ti := NewTemporalIndex(DefaultTemporalOptions()).Load(temporalPoints()...).(*TemporalIndex)
+	origin := NewCoordinates(-122, 47)
+
+	// the last hour
+	results := ti.NearbyBetween(origin, 5, temporalStart.Add(100*time.Minute), temporalStart.Add(160*time.Minute), AcceptAny)
+	assertEqual(t, 2, len(results))
+	assertEqual(t, "seattle-new", results[0].(*TimestampedPoint).Name)
+	assertEqual(t, "memphis", results[1].(*TimestampedPoint).Name)
+
+	// whole buckets
+	results = ti.NearbyBetween(origin, 5, temporalStart.Add(time.Hour), temporalStart.Add(2*time.Hour), AcceptAny)
+	assertEqual(t, 2, len(results))
+	assertEqual(t, "woodinville", results[0].(*TimestampedPoint).Name)
+	assertEqual(t, "memphis", results[1].(*TimestampedPoint).Name)
+
+	// open ended ranges
+	results = ti.NearbyBetween(origin, 5, temporalStart.Add(time.Hour), time.Time{}, AcceptAny)
+	assertEqual(t, 3, len(results))
+	results = ti.NearbyBetween(origin, 5, time.Time{}, temporalStart.Add(time.Hour), AcceptAny)
+	assertEqual(t, 2, len(results))
+	assertEqual(t, "seattle-old", results[0].(*TimestampedPoint).Name)
+	assertEqual(t, "tokyo", results[1].(*NamedPoint).Name)
+
+	// the range is half-open
+	results = ti.NearbyBetween(origin, 5, temporalStart.Add(90*time.Minute), temporalStart.Add(100*time.Minute), AcceptAny)
+	assertEqual(t, 1, len(results))
+	assertEqual(t, "woodinville", results[0].(*TimestampedPoint).Name)
+
+	assertEqual(t, 0, len(ti.NearbyBetween(origin, 5, temporalStart.Add(time.Hour), temporalStart, AcceptAny)))
+}
+
+func TestTemporalIndex_Nearby_Ties(t *testing.T) {
+	// tied Points in different buckets are returned in the order they were loaded
+	pts := []Point{
+		&TimestampedPoint{Point: NewCoordinates(10, 0), Name: "late", Time: temporalStart.Add(time.Hour)},
+		&TimestampedPoint{Point: NewCoordinates(-10, 0), Name: "early", Time: temporalStart},
+		&TimestampedPoint{Point: NewCoordinates(10.01, 0), Name: "late-farther", Time: temporalStart.Add(time.Hour)},
+	}
+	ti := NewTemporalIndex(DefaultTemporalOptions()).Load(pts...)
+	results := ti.Nearby(NewCoordinates(0, 0), 3, AcceptAny)
+	assertEqual(t, "late", results[0].(*TimestampedPoint).Name)
+	assertEqual(t, "early", results[1].(*TimestampedPoint).Name)
+	assertEqual(t, "late-farther", results[2].(*TimestampedPoint).Name)
+
+	// Points within TieEpsilonKm are tied across buckets
+	opts := DefaultTemporalOptions()
+	opts.KDTree.TieEpsilonKm = 5
+	ti = NewTemporalIndex(opts).Load(
+		&rankedTimestampedPoint{RankedPoint: &RankedPoint{Point: NewCoordinates(-10, 0), Name: "early", Rank: 1},
+			Time: temporalStart},
+		&rankedTimestampedPoint{RankedPoint: &RankedPoint{Point: NewCoordinates(10.01, 0), Name: "late-more-important",
+			Rank: 5}, Time: temporalStart.Add(time.Hour)},
+	)
+	results = ti.Nearby(NewCoordinates(0, 0), 2, AcceptAny)
+	assertEqual(t, "late-more-important", results[0].(*rankedTimestampedPoint).Name)
+	assertEqual(t, "early", results[1].(*rankedTimestampedPoint).Name)
+}
+
+func TestTemporalIndex_BucketDuration(t *testing.T) {
+	// a non-positive bucket duration uses the default
+	ti := NewTemporalIndex(TemporalOptions{KDTree: DefaultKDTreeOptions()})
+	ti.Load(temporalPoints()...)
+	assertEqual(t, 4, len(ti.buckets))
+	assertEqual(t, 5, len(ti.Nearby(NewCoordinates(-122, 47), 5, AcceptAny)))
+}
+
+func TestTemporalIndex_Add(t *testing.T) {
+	opts := DefaultTemporalOptions()
+	opts.BucketDuration = time.Minute
+	opts.KDTree.NodeSize = 2
+	ti := NewTemporalIndex(opts)
+	origin := NewCoordinates(-122, 47)
+
+	// add points out of order, one at a time
+	var pts []Point
+	for i := 0; i < 100; i++ {
+		pt := &TimestampedPoint{
+			Point: NewCoordinates(-122+float64(i%10)*0.1, 47+float64(i/10)*0.1),
+			Name:  fmt.Sprint(i),
+			Time:  temporalStart.Add(time.Duration((i*37)%100) * 30 * time.Second),
+		}
+		pts = append(pts, pt)
+		ti.Add(pt)
+	}
+	assertEqual(t, 50, len(ti.buckets))
+
+	from, to := temporalStart.Add(10*time.Minute), temporalStart.Add(20*time.Minute)
+	inRange := func(p Point) bool {
+		ts := p.(*TimestampedPoint).Time
+		return !ts.Before(from) && ts.Before(to)
+	}
+	expected := NewKDTreeIndex(opts.KDTree).Load(pts...).Nearby(origin, 10, inRange)
+	results := ti.NearbyBetween(origin, 10, from, to, AcceptAny)
+	assertEqual(t, len(expected), len(results))
+	for i := range expected {
+		assertEqual(t, distanceKm(origin, expected[i]), distanceKm(origin, results[i]))
+		assertEqual(t, true, inRange(results[i]))
+	}
+
+	ti.Load()
+	assertEqual(t, 0, len(ti.Nearby(origin, 10, AcceptAny)))
+}
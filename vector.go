@@ -0,0 +1,56 @@
+package neighborhood
+
+import "math"
+
+// vec3 is a vector in Earth-centered 3D space; unit vectors are locations on the unit sphere
+type vec3 struct {
+	x, y, z float64
+}
+
+// toVec3 converts a location to a unit vector
+func toVec3(lon, lat float64) vec3 {
+	cosLat := math.Cos(lat * rad)
+	return vec3{
+		x: cosLat * math.Cos(lon*rad),
+		y: cosLat * math.Sin(lon*rad),
+		z: math.Sin(lat * rad),
+	}
+}
+
+// lonLat converts a vector to the location it points at
+func (v vec3) lonLat() (lon, lat float64) {
+	return math.Atan2(v.y, v.x) / rad, math.Atan2(v.z, math.Hypot(v.x, v.y)) / rad
+}
+
+func (v vec3) add(w vec3) vec3 { return vec3{v.x + w.x, v.y + w.y, v.z + w.z} }
+
+func (v vec3) sub(w vec3) vec3 { return vec3{v.x - w.x, v.y - w.y, v.z - w.z} }
+
+func (v vec3) scale(s float64) vec3 { return vec3{v.x * s, v.y * s, v.z * s} }
+
+func (v vec3) dot(w vec3) float64 { return v.x*w.x + v.y*w.y + v.z*w.z }
+
+func (v vec3) cross(w vec3) vec3 {
+	return vec3{
+		x: v.y*w.z - v.z*w.y,
+		y: v.z*w.x - v.x*w.z,
+		z: v.x*w.y - v.y*w.x,
+	}
+}
+
+func (v vec3) norm() float64 { return math.Sqrt(v.dot(v)) }
+
+// normalize gets the unit vector in the direction of v, or the zero vector if v is zero
+func (v vec3) normalize() vec3 {
+	n := v.norm()
+	if n == 0 {
+		return v
+	}
+	return v.scale(1 / n)
+}
+
+// angle gets the angle between two vectors in radians
+func angle(v, w vec3) float64 {
+	// more accurate than the arc cosine of the dot product for small angles
+	return math.Atan2(v.cross(w).norm(), v.dot(w))
+}